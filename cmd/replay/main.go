@@ -0,0 +1,39 @@
+// Command replay plays a replay file without a window and prints the outcome.
+// It only needs the sim package, so it builds without cgo or a display.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/ShaolingPu/battleCity/sim"
+)
+
+func main() {
+	levelsPath := flag.String("levels", "", "directory or .zip of level files the replay was recorded with")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: replay [-levels dir|zip] file")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	levels, err := sim.LoadLevelsFrom(*levelsPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	replay, err := sim.LoadReplay(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	w, err := replay.Play(levels)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(w.Outcome())
+}
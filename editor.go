@@ -0,0 +1,309 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ShaolingPu/battleCity/sim"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// tool is what the construction mode puts down: a tile, or with tile 0 a
+// spawn point or player start.
+type tool struct {
+	name string
+	tile byte
+}
+
+var tools = []tool{
+	{"BRICK", '#'},
+	{"STEEL", '@'},
+	{"WATER", '%'},
+	{"GRASS", '~'},
+	{"ICE", '-'},
+	{"ERASE", '.'},
+	{"SPAWN", 0},
+	{"PLAYER 1", 0},
+	{"PLAYER 2", 0},
+}
+
+const (
+	toolSpawn = iota + 6
+	toolPlayer1
+	toolPlayer2
+)
+
+var toolKeys = [...]ebiten.Key{
+	ebiten.KeyDigit1, ebiten.KeyDigit2, ebiten.KeyDigit3,
+	ebiten.KeyDigit4, ebiten.KeyDigit5, ebiten.KeyDigit6,
+	ebiten.KeyDigit7, ebiten.KeyDigit8, ebiten.KeyDigit9,
+}
+
+var tileTypes = map[byte]int{'#': sim.Brick, '@': sim.Steel, '%': sim.Water, '~': sim.Grass, '-': sim.Ice}
+
+// Editor is the construction mode: a level being built and the file it is
+// saved to.
+type Editor struct {
+	level  *sim.Level
+	path   string
+	c, r   int // the cursor, in tiles
+	tool   int
+	mouse  image.Point // where the mouse was, to tell when it moves
+	status string
+	// painting is set while a press that started in the editor is held, so
+	// the one that opened it doesn't paint.
+	painting bool
+}
+
+// newEditor opens the level file at path, or starts an empty level with only
+// the castle walls if there is no file there yet.
+func newEditor(path string) (*Editor, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Editor{level: emptyLevel(filepath.Base(path)), path: path}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	l, err := sim.DecodeLevel(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &Editor{level: l, path: path}, nil
+}
+
+func emptyLevel(name string) *sim.Level {
+	l := sim.NewLevel()
+	l.Name = name
+	l.Grid = make([]string, sim.GridSize)
+	for r := range l.Grid {
+		l.Grid[r] = strings.Repeat(".", sim.GridSize)
+	}
+	c0, r0 := int(l.Castle[0])/tileSize, int(l.Castle[1])/tileSize
+	for r := r0 - 1; r <= r0+2; r++ {
+		for c := c0 - 1; c <= c0+2; c++ {
+			if !inCastle(l, c, r) {
+				setTile(l, c, r, '#')
+			}
+		}
+	}
+	return l
+}
+
+func inCastle(l *sim.Level, c, r int) bool {
+	c0, r0 := int(l.Castle[0])/tileSize, int(l.Castle[1])/tileSize
+	return c >= c0 && c < c0+2 && r >= r0 && r < r0+2
+}
+
+func setTile(l *sim.Level, c, r int, ch byte) {
+	if r < 0 || r >= len(l.Grid) || c < 0 || c >= len(l.Grid[r]) {
+		return
+	}
+	row := []byte(l.Grid[r])
+	row[c] = ch
+	l.Grid[r] = string(row)
+}
+
+// size is how many tiles across the current tool puts down.
+func (e *Editor) size() int {
+	if tools[e.tool].tile == 0 {
+		return 2
+	}
+	return 1
+}
+
+func (e *Editor) clamp() {
+	last := sim.GridSize - e.size()
+	for _, v := range []*int{&e.c, &e.r} {
+		if *v < 0 {
+			*v = 0
+		}
+		if *v > last {
+			*v = last
+		}
+	}
+}
+
+// apply uses the current tool at the cursor.
+func (e *Editor) apply() {
+	e.status = ""
+	l := e.level
+	switch t := tools[e.tool]; {
+	case t.tile != 0:
+		if !inCastle(l, e.c, e.r) {
+			setTile(l, e.c, e.r, t.tile)
+		}
+	case e.tool == toolSpawn:
+		e.toggleSpawn()
+	default:
+		l.Players[e.tool-toolPlayer1] = sim.StartAt(e.c, e.r)
+	}
+}
+
+// toggleSpawn adds a spawn point at the cursor, or takes away the one there.
+func (e *Editor) toggleSpawn() {
+	l := e.level
+	for i, p := range l.Spawns {
+		if c, r := sim.SpawnTile(p); c != e.c || r != e.r {
+			continue
+		}
+		if len(l.Spawns) == 1 {
+			e.status = "NEED A SPAWN"
+			return
+		}
+		l.Spawns = append(l.Spawns[:i], l.Spawns[i+1:]...)
+		return
+	}
+	l.Spawns = append(l.Spawns, sim.SpawnAt(e.c, e.r))
+}
+
+// save writes the level to its file and logs anything that makes it
+// unplayable, as validate-levels would.
+func (e *Editor) save() {
+	if err := os.WriteFile(e.path, e.level.Encode(), 0o644); err != nil {
+		log.Printf("save level: %v", err)
+		e.status = "SAVE FAILED"
+		return
+	}
+	problems := e.level.Problems()
+	for _, p := range problems {
+		log.Printf("%s: %s", e.path, p)
+	}
+	e.status = "SAVED"
+	if len(problems) > 0 {
+		e.status = fmt.Sprintf("SAVED, %d PROBLEMS", len(problems))
+	}
+}
+
+// repeating reports whether a held key should act this update, once when
+// pressed and then repeatedly after a moment.
+func repeating(key ebiten.Key) bool {
+	d := inpututil.KeyPressDuration(key)
+	return d == 1 || d >= 15 && d%4 == 0
+}
+
+func (g *Game) updateEditor() {
+	e := g.editor
+	switch {
+	case repeating(ebiten.KeyLeft):
+		e.c--
+	case repeating(ebiten.KeyRight):
+		e.c++
+	case repeating(ebiten.KeyUp):
+		e.r--
+	case repeating(ebiten.KeyDown):
+		e.r++
+	}
+	x, y := ebiten.CursorPosition()
+	if p := image.Pt(x, y); p != e.mouse {
+		e.mouse = p
+		if p.In(image.Rect(0, 0, screenWidth, screenHeight)) {
+			e.c, e.r = x/tileSize, y/tileSize
+		}
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyTab) {
+		e.tool = (e.tool + 1) % len(tools)
+	}
+	for i, key := range toolKeys {
+		if inpututil.IsKeyJustPressed(key) {
+			e.tool = i
+		}
+	}
+	e.clamp()
+
+	// Tiles paint while the button is held, the rest go down once a press.
+	held := ebiten.IsKeyPressed(ebiten.KeySpace) || ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft)
+	pressed := inpututil.IsKeyJustPressed(ebiten.KeySpace) || inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft)
+	if pressed {
+		e.painting = true
+	}
+	if !held {
+		e.painting = false
+	}
+	if pressed || e.painting && tools[e.tool].tile != 0 {
+		e.apply()
+	}
+	if ebiten.IsMouseButtonPressed(ebiten.MouseButtonRight) && !inCastle(e.level, e.c, e.r) {
+		setTile(e.level, e.c, e.r, '.')
+	}
+
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyS):
+		e.save()
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnter):
+		g.testLevel()
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		g.editor = nil
+		g.mode = ModeTitle
+	}
+}
+
+// testLevel plays the level being built on its own, coming back to the
+// editor when the stage is cleared or lost. Test games aren't recorded.
+func (g *Game) testLevel() {
+	l := *g.editor.level
+	l.Enemies = append([]sim.EnemyType(nil), l.Enemies...)
+	l.Spawns = append([][2]float64(nil), l.Spawns...)
+	l.Grid = append([]string(nil), l.Grid...)
+	g.lag = 0
+	g.world = sim.NewWorld([]*sim.Level{&l}, 1, g.twoPlayer, g.seed)
+	g.world.SetRules(g.rules)
+	g.recording = nil
+	g.sources = g.playerSources()
+	g.mode = ModeGame
+}
+
+func (g *Game) DrawEditor(screen *ebiten.Image) {
+	e := g.editor
+	l := e.level
+	screen.Fill(color.Black)
+	gridColor := color.RGBA{0x30, 0x30, 0x30, 0xff}
+	for i := 1; i < sim.GridSize; i++ {
+		v := float32(i * tileSize)
+		vector.StrokeLine(screen, v, 0, v, screenHeight, 1, gridColor, false)
+		vector.StrokeLine(screen, 0, v, screenWidth, v, 1, gridColor, false)
+	}
+
+	op := &ebiten.DrawImageOptions{}
+	draw := func(img *ebiten.Image, x, y float64) {
+		op.GeoM.Reset()
+		op.GeoM.Scale(2, 2)
+		op.GeoM.Translate(x, y)
+		screen.DrawImage(img, op)
+	}
+	for r, row := range l.Grid {
+		for c := 0; c < len(row); c++ {
+			if t, ok := tileTypes[row[c]]; ok {
+				draw(otherImage(&sim.Other{T: t}), float64(c*tileSize), float64(r*tileSize))
+			}
+		}
+	}
+	draw(castleImage, l.Castle[0], l.Castle[1])
+	for _, p := range l.Spawns {
+		draw(enemy1Image, p[0], p[1])
+	}
+	draw(player1Image, l.Players[0][0], l.Players[0][1])
+	draw(player2Image, l.Players[1][0], l.Players[1][1])
+
+	size := float32(e.size() * tileSize)
+	vector.StrokeRect(screen, float32(e.c*tileSize), float32(e.r*tileSize), size, size, 2, color.White, false)
+
+	vector.DrawFilledRect(screen, 0, screenHeight-20, screenWidth, 20, color.RGBA{0, 0, 0, 0xc0}, false)
+	text.Draw(screen, fmt.Sprintf("%d %s", e.tool+1, tools[e.tool].name), smallArcadeFont, 4, screenHeight-5, color.White)
+	help := e.status
+	if help == "" {
+		help = "S SAVE ENTER PLAY"
+	}
+	b := text.BoundString(smallArcadeFont, help)
+	text.Draw(screen, help, smallArcadeFont, screenWidth-b.Dx()-4, screenHeight-5, color.White)
+}
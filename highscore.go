@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const maxHighScores = 10
+
+type HighScore struct {
+	Initials string    `json:"initials"`
+	Score    int       `json:"score"`
+	Level    int       `json:"level"`
+	Date     time.Time `json:"date"`
+}
+
+// HighScores is the top ten table, best score first.
+type HighScores []HighScore
+
+func highScoresPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "battleCity", "highscores.json"), nil
+}
+
+// LoadHighScores reads the saved table. A missing file gives an empty table,
+// and so does an unreadable or corrupt one after logging why.
+func LoadHighScores() HighScores {
+	path, err := highScoresPath()
+	if err != nil {
+		log.Printf("high scores: %v", err)
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		log.Printf("high scores: %v", err)
+		return nil
+	}
+	var h HighScores
+	if err := json.Unmarshal(data, &h); err != nil {
+		log.Printf("high scores: ignoring %s: %v", path, err)
+		return nil
+	}
+	// Don't trust the file to be sorted, sized or sane.
+	valid := h[:0]
+	for _, e := range h {
+		if e.Score > 0 {
+			valid = append(valid, e)
+		}
+	}
+	h = valid
+	sort.SliceStable(h, func(i, j int) bool { return h[i].Score > h[j].Score })
+	if len(h) > maxHighScores {
+		h = h[:maxHighScores]
+	}
+	return h
+}
+
+// Save writes the table through a temporary file, so a crash halfway leaves
+// the old table in place.
+func (h HighScores) Save() error {
+	path, err := highScoresPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Top returns the best score, or 0 for an empty table.
+func (h HighScores) Top() int {
+	if len(h) == 0 {
+		return 0
+	}
+	return h[0].Score
+}
+
+// Qualifies reports whether score would make it into the table.
+func (h HighScores) Qualifies(score int) bool {
+	if score <= 0 {
+		return false
+	}
+	return len(h) < maxHighScores || score > h[len(h)-1].Score
+}
+
+// Add puts e in its place, below any equal score, and drops whatever falls
+// off the bottom.
+func (h *HighScores) Add(e HighScore) {
+	i := sort.Search(len(*h), func(i int) bool { return (*h)[i].Score < e.Score })
+	*h = append(*h, HighScore{})
+	copy((*h)[i+1:], (*h)[i:])
+	(*h)[i] = e
+	if len(*h) > maxHighScores {
+		*h = (*h)[:maxHighScores]
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"github.com/ShaolingPu/battleCity/sim"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// KeyboardInput reads one player from the keyboard. Fire triggers on the
+// press, holding the key does not keep shooting.
+type KeyboardInput struct {
+	Up    ebiten.Key
+	Right ebiten.Key
+	Down  ebiten.Key
+	Left  ebiten.Key
+	Fire  ebiten.Key
+}
+
+var (
+	player1Keys = &KeyboardInput{Up: ebiten.KeyW, Right: ebiten.KeyD, Down: ebiten.KeyS, Left: ebiten.KeyA, Fire: ebiten.KeyF}
+	player2Keys = &KeyboardInput{Up: ebiten.KeyUp, Right: ebiten.KeyRight, Down: ebiten.KeyDown, Left: ebiten.KeyLeft, Fire: ebiten.KeyControlRight}
+)
+
+func (k *KeyboardInput) Input() sim.Input {
+	return sim.Input{
+		Up:    ebiten.IsKeyPressed(k.Up),
+		Right: ebiten.IsKeyPressed(k.Right),
+		Down:  ebiten.IsKeyPressed(k.Down),
+		Left:  ebiten.IsKeyPressed(k.Left),
+		Fire:  inpututil.IsKeyJustPressed(k.Fire),
+	}
+}
+
+const stickDeadZone = 0.5
+
+// GamepadInput reads one player from the Index-th connected gamepad, using
+// the d-pad or left stick to steer and the bottom or right face button to fire.
+// It stays idle while that gamepad is not connected.
+type GamepadInput struct {
+	Index int
+}
+
+func (p *GamepadInput) Input() sim.Input {
+	ids := ebiten.AppendGamepadIDs(nil)
+	if p.Index >= len(ids) {
+		return sim.Input{}
+	}
+	id := ids[p.Index]
+	if !ebiten.IsStandardGamepadLayoutAvailable(id) {
+		x, y := ebiten.GamepadAxisValue(id, 0), ebiten.GamepadAxisValue(id, 1)
+		return sim.Input{
+			Up:    y < -stickDeadZone,
+			Right: x > stickDeadZone,
+			Down:  y > stickDeadZone,
+			Left:  x < -stickDeadZone,
+			Fire:  inpututil.IsGamepadButtonJustPressed(id, ebiten.GamepadButton0),
+		}
+	}
+	x := ebiten.StandardGamepadAxisValue(id, ebiten.StandardGamepadAxisLeftStickHorizontal)
+	y := ebiten.StandardGamepadAxisValue(id, ebiten.StandardGamepadAxisLeftStickVertical)
+	return sim.Input{
+		Up:    y < -stickDeadZone || ebiten.IsStandardGamepadButtonPressed(id, ebiten.StandardGamepadButtonLeftTop),
+		Right: x > stickDeadZone || ebiten.IsStandardGamepadButtonPressed(id, ebiten.StandardGamepadButtonLeftRight),
+		Down:  y > stickDeadZone || ebiten.IsStandardGamepadButtonPressed(id, ebiten.StandardGamepadButtonLeftBottom),
+		Left:  x < -stickDeadZone || ebiten.IsStandardGamepadButtonPressed(id, ebiten.StandardGamepadButtonLeftLeft),
+		Fire: inpututil.IsStandardGamepadButtonJustPressed(id, ebiten.StandardGamepadButtonRightBottom) ||
+			inpututil.IsStandardGamepadButtonJustPressed(id, ebiten.StandardGamepadButtonRightRight),
+	}
+}
+
+// anyInput merges several sources so one player can use any of them.
+type anyInput []sim.InputSource
+
+func (a anyInput) Input() sim.Input {
+	var in sim.Input
+	for _, s := range a {
+		in = in.Merge(s.Input())
+	}
+	return in
+}
+
+// latchedInput reads a source once per Update and hands what it saw to the
+// ticks after it. The world steps at its own rate, so an Update may run no
+// tick or several; a fire press goes to exactly one tick all the same.
+type latchedInput struct {
+	source sim.InputSource
+	in     sim.Input // as last read, with Fire set until a tick takes it
+}
+
+// Read reads the source, keeping any fire press no tick has taken yet.
+func (l *latchedInput) Read() {
+	fire := l.in.Fire
+	l.in = l.source.Input()
+	l.in.Fire = l.in.Fire || fire
+}
+
+func (l *latchedInput) Input() sim.Input {
+	in := l.in
+	l.in.Fire = false
+	return in
+}
+
+func playerInput(player int) sim.InputSource {
+	if player == 0 {
+		return &latchedInput{source: anyInput{player1Keys, &GamepadInput{Index: 0}}}
+	}
+	return &latchedInput{source: anyInput{player2Keys, &GamepadInput{Index: 1}}}
+}
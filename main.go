@@ -16,41 +16,33 @@ package main
 
 import (
 	"bytes"
+	"flag"
 	"fmt"
 	"image"
 	"image/color"
 	_ "image/png"
 	"log"
 	"math"
-	"math/rand"
-	"strings"
+	"os"
+	"time"
+	"unicode"
 
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/opentype"
 
 	fonts "github.com/ShaolingPu/battleCity/resources/fonts/tank"
 	resources "github.com/ShaolingPu/battleCity/resources/images/tank"
-	levels "github.com/ShaolingPu/battleCity/resources/levels/tank"
+	"github.com/ShaolingPu/battleCity/sim"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/hajimehoshi/ebiten/v2/text"
 	"github.com/hajimehoshi/ebiten/v2/vector"
 )
 
-type Entity interface {
-	GetInfo() (Width, Height int, X, Y float64)
-}
-
-var (
-	levels_enemies [35][4]int
-	born_positions [3][2]int
-	max_enemies    int
-)
-
 const (
-	screenWidth   = 416
-	screenHeight  = 416
-	tileSize      = 16
+	screenWidth   = sim.ScreenWidth
+	screenHeight  = sim.ScreenHeight
+	tileSize      = sim.TileSize
 	fontSize      = 24
 	titleFontSize = fontSize * 1.5
 	smallFontSize = fontSize / 2
@@ -62,6 +54,7 @@ var (
 	steelImage   *ebiten.Image
 	grassImage   *ebiten.Image
 	waterImage   *ebiten.Image
+	iceImage     *ebiten.Image
 	player1Image *ebiten.Image
 	player2Image *ebiten.Image
 	enemy1Image  *ebiten.Image
@@ -72,6 +65,12 @@ var (
 	enemy6Image  *ebiten.Image
 	enemy7Image  *ebiten.Image
 	enemy8Image  *ebiten.Image
+	bulletImage  *ebiten.Image
+	castleImage  *ebiten.Image
+
+	destroyedCastleImage *ebiten.Image
+	explosionImages      [3]*ebiten.Image
+	powerUpImages        [sim.PowerUpTypes]*ebiten.Image
 )
 
 var (
@@ -87,20 +86,30 @@ func init() {
 		log.Fatal(err)
 	}
 	tilesImage = ebiten.NewImageFromImage(img)
-	levels_enemies = [35][4]int{{18, 2, 0, 0}, {14, 4, 0, 2}, {14, 4, 0, 2}, {2, 5, 10, 3}, {8, 5, 5, 2},
-		{9, 2, 7, 2}, {7, 4, 6, 3}, {7, 4, 7, 2}, {6, 4, 7, 3}, {12, 2, 4, 2},
-		{5, 5, 4, 6}, {0, 6, 8, 6}, {0, 8, 8, 4}, {0, 4, 10, 6}, {0, 2, 10, 8},
-		{16, 2, 0, 2}, {8, 2, 8, 2}, {2, 8, 6, 4}, {4, 4, 4, 8}, {2, 8, 2, 8},
-		{6, 2, 8, 4}, {6, 8, 2, 4}, {0, 10, 4, 6}, {10, 4, 4, 2}, {0, 8, 2, 10},
-		{4, 6, 4, 6}, {2, 8, 2, 8}, {15, 2, 2, 1}, {0, 4, 10, 6}, {4, 8, 4, 4},
-		{3, 8, 3, 6}, {6, 4, 2, 8}, {4, 4, 4, 8}, {0, 10, 4, 6}, {0, 6, 4, 10},
-	}
-	born_positions = [3][2]int{{3, 3}, {192, 3}, {381, 3}}
-	max_enemies = 4
-	// brickImage = tilesImage.SubImage(image.Rect(48, 64, tileSize/2, tileSize/2)).(*ebiten.Image)
-	// steelImage = tilesImage.SubImage(image.Rect(48, 72, tileSize/2, tileSize/2)).(*ebiten.Image)
-	// grassImage = tilesImage.SubImage(image.Rect(56, 72, tileSize/2, tileSize/2)).(*ebiten.Image)
-	// waterImage = tilesImage.SubImage(image.Rect(64, 64, tileSize/2, tileSize/2)).(*ebiten.Image)
+	brickImage = tilesImage.SubImage(image.Rect(56, 64, 56+tileSize/2, 64+tileSize/2)).(*ebiten.Image)
+	steelImage = tilesImage.SubImage(image.Rect(48, 72, 48+tileSize/2, 72+tileSize/2)).(*ebiten.Image)
+	waterImage = tilesImage.SubImage(image.Rect(64, 64, 64+tileSize/2, 64+tileSize/2)).(*ebiten.Image)
+	grassImage = tilesImage.SubImage(image.Rect(56, 72, 56+tileSize/2, 72+tileSize/2)).(*ebiten.Image)
+	iceImage = tilesImage.SubImage(image.Rect(64, 72, 64+tileSize/2, 72+tileSize/2)).(*ebiten.Image)
+	player1Image = tilesImage.SubImage(image.Rect(0, 0, tileSize-3, tileSize-3)).(*ebiten.Image)
+	player2Image = tilesImage.SubImage(image.Rect(16, 0, 16+tileSize-3, tileSize-3)).(*ebiten.Image)
+	enemy1Image = tilesImage.SubImage(image.Rect(32, 0, 32+13, 15)).(*ebiten.Image)
+	enemy2Image = tilesImage.SubImage(image.Rect(48, 0, 48+13, 15)).(*ebiten.Image)
+	enemy3Image = tilesImage.SubImage(image.Rect(64, 0, 64+13, 15)).(*ebiten.Image)
+	enemy4Image = tilesImage.SubImage(image.Rect(80, 0, 80+13, 15)).(*ebiten.Image)
+	enemy5Image = tilesImage.SubImage(image.Rect(32, 16, 32+13, 16+15)).(*ebiten.Image)
+	enemy6Image = tilesImage.SubImage(image.Rect(48, 16, 48+13, 16+15)).(*ebiten.Image)
+	enemy7Image = tilesImage.SubImage(image.Rect(64, 16, 64+13, 16+15)).(*ebiten.Image)
+	enemy8Image = tilesImage.SubImage(image.Rect(80, 16, 80+13, 16+15)).(*ebiten.Image)
+	bulletImage = tilesImage.SubImage(image.Rect(75, 74, 75+3, 74+4)).(*ebiten.Image)
+	castleImage = tilesImage.SubImage(image.Rect(0, 15, tileSize, 15+tileSize)).(*ebiten.Image)
+	destroyedCastleImage = tilesImage.SubImage(image.Rect(16, 15, 16+tileSize, 15+tileSize)).(*ebiten.Image)
+	explosionImages[0] = tilesImage.SubImage(image.Rect(8, 88, 8+tileSize, 88+tileSize)).(*ebiten.Image)
+	explosionImages[1] = tilesImage.SubImage(image.Rect(40, 88, 40+tileSize, 88+tileSize)).(*ebiten.Image)
+	explosionImages[2] = tilesImage.SubImage(image.Rect(64, 80, 64+2*tileSize, 80+2*tileSize)).(*ebiten.Image)
+	for i := range powerUpImages {
+		powerUpImages[i] = tilesImage.SubImage(image.Rect(i*tileSize, 32, (i+1)*tileSize, 32+tileSize)).(*ebiten.Image)
+	}
 }
 
 func init() {
@@ -141,536 +150,312 @@ const (
 	ModeTitle Mode = iota
 	ModeGame
 	ModeGameOver
+	ModeEnterInitials
+	ModeHighScores
+	ModeEditor
 )
 
-type Bullet struct {
-	Image       *ebiten.Image
-	X           float64
-	Y           float64
-	F           int
-	SpeedFactor float64
-	Owner       *Tank
-}
-
-type Tank struct {
-	enemy       bool
-	Image       *ebiten.Image
-	X           float64
-	Y           float64
-	Face        int
-	SpeedFactor float64
-	Failed      bool
-}
-
-type Other struct {
-	Image *ebiten.Image
-	X     float64
-	Y     float64
-	T     int
-}
-
-func (o *Other) GetInfo() (Width, Height int, X, Y float64) {
-	w, h := o.Image.Bounds().Dx(), o.Image.Bounds().Dy()
-	return w * 2, h * 2, o.X, o.Y
-}
-
-func (t *Tank) Turn(i int) {
-	t.Face = i
-}
-
-func (t *Tank) GetInfo() (Width, Height int, X, Y float64) {
-	w, h := t.Image.Bounds().Dx(), t.Image.Bounds().Dy()
-	return w * 2, h * 2, t.X, t.Y
-}
+// Title screen entries.
+const (
+	menuOnePlayer = iota
+	menuTwoPlayers
+	menuConstruction
+	menuEntries
+)
 
-func (b *Bullet) Move() {
-	switch b.F {
-	case 0:
-		b.Y -= b.SpeedFactor
-	case 1:
-		b.X += b.SpeedFactor
-	case 2:
-		b.Y += b.SpeedFactor
-	default:
-		b.X -= b.SpeedFactor
-	}
+type Game struct {
+	mode      Mode
+	twoPlayer bool
+	seed      int64
+	rules     sim.Rules
+	levels    []*sim.Level
+	world     *sim.World
+	sources   [2]sim.InputSource
+	recording *sim.Replay
+	replay    *sim.Replay
+	lag       float64 // seconds of game time not yet stepped
+	menu      int     // the title screen entry picked
+	editFile  string
+	editor    *Editor // while in construction mode or test playing its level
+
+	highScores HighScores
+	pending    []*sim.Tank // players still to enter their initials
+	initials   []rune
+	// audioContext *audio.Context
+	// jumpPlayer   *audio.Player
+	// hitPlayer    *audio.Player
 }
 
-func RectCollision(aWidth, aHeight int, aX, aY float64, bWidth, bHeight int, bX, bY float64, eqa bool) bool {
-	top, left := aY, aX
-	bottom, right := aY+float64(aHeight), aX+float64(aWidth)
-	// top left
-	x, y := bX, bY
-	if eqa && y >= top && y <= bottom && x >= left && x <= right {
-		return true
-	} else if y > top && y < bottom && x > left && x < right {
-		return true
-	}
-
-	// top right
-	x, y = bX+float64(bWidth), bY
-	if eqa && y >= top && y <= bottom && x >= left && x <= right {
-		return true
-	} else if y > top && y < bottom && x > left && x < right {
-		return true
-	}
-
-	// bottom left
-	x, y = bX, bY+float64(bHeight)
-	if eqa && y >= top && y <= bottom && x >= left && x <= right {
-		return true
-	} else if y > top && y < bottom && x > left && x < right {
-		return true
-	}
-
-	// bottom right
-	x, y = bX+float64(bWidth), bY+float64(bHeight)
-	if eqa && y >= top && y <= bottom && x >= left && x <= right {
-		return true
-	} else if y > top && y < bottom && x > left && x < right {
-		return true
+func tankImage(w *sim.World, t *sim.Tank) *ebiten.Image {
+	if t == w.P0 {
+		return player1Image
 	}
-
-	return false
-}
-
-func CheckCollision(A, B Entity, eqa bool) bool {
-	aWidth, aHeight, aX, aY := A.GetInfo()
-	bWidth, bHeight, bX, bY := B.GetInfo()
-	return RectCollision(aWidth, aHeight, aX, aY, bWidth, bHeight, bX, bY, eqa)
-
-}
-
-func (b *Bullet) GetInfo() (Width, Height int, X, Y float64) {
-	w, h := b.Image.Bounds().Dx(), b.Image.Bounds().Dy()
-	return w * 2, h * 2, b.X, b.Y
-}
-
-func (t *Tank) Fire() *Bullet {
-	w, h := float64(t.Image.Bounds().Dx()), float64(t.Image.Bounds().Dy())
-	img := tilesImage.SubImage(image.Rect(75, 74, 75+3, 74+4)).(*ebiten.Image)
-	dx, dy := float64(img.Bounds().Dx()), float64(img.Bounds().Dy())
-
-	var x, y float64
-	switch t.Face {
-	case 0:
-		x, y = t.X+w-dx, t.Y-2*dy
-	case 1:
-		x, y = t.X+h*2, t.Y+w-dx
-	case 2:
-		x, y = t.X+w-dx, t.Y+h*2
-	default:
-		x, y = t.X-h, t.Y+w-dx
+	if !t.Enemy {
+		return player2Image
 	}
-	bullet := &Bullet{
-		Image:       img,
-		X:           x,
-		Y:           y,
-		F:           t.Face,
-		SpeedFactor: float64(2),
-		Owner:       t,
+	typ := t.Type
+	// Bonus tanks flash red.
+	if t.Bonus && w.Tick/8%2 == 0 {
+		typ += 4
 	}
-	return bullet
-}
-
-type EnemyType int
-
-func NewEnemy(t EnemyType, F int, x, y float64) *Tank {
-	var img *ebiten.Image
-	switch t {
+	switch typ {
 	case 0:
-		img = tilesImage.SubImage(image.Rect(32, 0, 32+13, 15)).(*ebiten.Image)
+		return enemy1Image
 	case 1:
-		img = tilesImage.SubImage(image.Rect(48, 0, 48+13, 15)).(*ebiten.Image)
+		return enemy2Image
 	case 2:
-		img = tilesImage.SubImage(image.Rect(64, 0, 64+13, 15)).(*ebiten.Image)
+		return enemy3Image
 	case 3:
-		img = tilesImage.SubImage(image.Rect(80, 0, 80+13, 15)).(*ebiten.Image)
+		return enemy4Image
 	case 4:
-		img = tilesImage.SubImage(image.Rect(32, 16, 32+13, 16+15)).(*ebiten.Image)
+		return enemy5Image
 	case 5:
-		img = tilesImage.SubImage(image.Rect(48, 16, 48+13, 16+15)).(*ebiten.Image)
+		return enemy6Image
 	case 6:
-		img = tilesImage.SubImage(image.Rect(64, 16, 64+13, 16+15)).(*ebiten.Image)
-	case 7:
-		img = tilesImage.SubImage(image.Rect(80, 16, 80+13, 16+15)).(*ebiten.Image)
-	}
-
-	tank := &Tank{
-		Image:       img,
-		X:           x,
-		Y:           y,
-		Face:        F,
-		Failed:      false,
-		enemy:       true,
-		SpeedFactor: 0.5,
-	}
-	return tank
-}
-
-func NewOther(x, y float64, t int) *Other {
-	var img *ebiten.Image
-	switch t {
-	case 0:
-		img = tilesImage.SubImage(image.Rect(56, 64, 56+tileSize/2, 64+tileSize/2)).(*ebiten.Image)
-	case 1:
-		img = tilesImage.SubImage(image.Rect(48, 72, 48+tileSize/2, 72+tileSize/2)).(*ebiten.Image)
-	case 2:
-		img = tilesImage.SubImage(image.Rect(64, 64, 64+tileSize/2, 64+tileSize/2)).(*ebiten.Image)
+		return enemy7Image
 	default:
-		img = tilesImage.SubImage(image.Rect(56, 72, 56+tileSize/2, 72+tileSize/2)).(*ebiten.Image)
-	}
-	o := &Other{
-		Image: img,
-		X:     x,
-		Y:     y,
-		T:     t,
-	}
-	return o
-}
-
-func NewPlayer(player int) *Tank {
-	var img *ebiten.Image
-	var x, y float64
-	if player == 0 {
-		img = tilesImage.SubImage(image.Rect(0, 0, tileSize-3, tileSize-3)).(*ebiten.Image)
-		x, y = 144, 384
-	} else {
-		img = tilesImage.SubImage(image.Rect(16, 0, 16+tileSize-3, tileSize-3)).(*ebiten.Image)
-		x, y = 240+3, 384
+		return enemy8Image
 	}
-
-	tank := &Tank{
-		enemy:       false,
-		Image:       img,
-		X:           x,
-		Y:           y,
-		Face:        0,
-		SpeedFactor: 1,
-	}
-	return tank
 }
 
-func GetLevel(i int) []string {
-	file := fmt.Sprintf("levels/%d", i)
-	data, err := levels.Levels.ReadFile(file)
-	if err != nil {
-		log.Fatal(err)
-	}
-	return strings.Split(string(data), "\n")
-}
-
-type Castle struct {
-	Image          *ebiten.Image
-	DestroyedImage *ebiten.Image
-	mode           Mode //standing: 0, exploding: 1, destroyed: 2
-}
-
-func NewCastle() *Castle {
-	var img, destroyedImage *ebiten.Image
-	img = tilesImage.SubImage(image.Rect(0, 15, tileSize, 15+tileSize)).(*ebiten.Image)
-	destroyedImage = tilesImage.SubImage(image.Rect(0, 15, tileSize, 15+tileSize)).(*ebiten.Image)
-	castle := &Castle{
-		Image:          img,
-		DestroyedImage: destroyedImage,
-		mode:           0,
-	}
-	return castle
-}
-
-type Game struct {
-	mode      Mode
-	p0        *Tank
-	p1        *Tank
-	twoPlayer bool
-	bullets   map[*Bullet]struct{}
-	// players   map[*Tank]struct{}
-	enemys       map[*Tank]struct{}
-	castle       *Castle
-	level        int
-	mapLevel     []string
-	others       map[*Other]struct{}
-	enemies_left []int
-	idx          int
-	// audioContext *audio.Context
-	// jumpPlayer   *audio.Player
-	// hitPlayer    *audio.Player
-}
-
-func (g *Game) addPlayer() {
-	g.p0 = NewPlayer(0)
-	if g.twoPlayer {
-		g.p1 = NewPlayer(1)
+func otherImage(o *sim.Other) *ebiten.Image {
+	switch o.T {
+	case sim.Brick:
+		return brickImage
+	case sim.Steel:
+		return steelImage
+	case sim.Water:
+		return waterImage
+	case sim.Ice:
+		return iceImage
+	default:
+		return grassImage
 	}
 }
 
-func (g *Game) drawTank(t *Tank, screen *ebiten.Image) {
+func (g *Game) drawTank(t *sim.Tank, screen *ebiten.Image) {
 	if t == nil || t.Failed {
 		return
 	}
+	// A frozen tank blinks until it thaws.
+	if t.Frozen > 0 && t.Frozen/8%2 == 0 {
+		return
+	}
 	op := &ebiten.DrawImageOptions{}
-	img := t.Image
+	img := tankImage(g.world, t)
 	w, h := img.Bounds().Dx(), img.Bounds().Dy()
 	op.GeoM.Translate(float64(-w)/2, float64(-h)/2)
 	angle := float64(t.Face) * (math.Pi / 2)
 	op.GeoM.Rotate(angle)
 	op.GeoM.Translate(float64(w)/2.0, float64(h)/2.0)
+	x, y := t.Lerp(g.alpha())
 	op.GeoM.Scale(2, 2)
-	op.GeoM.Translate(t.X, t.Y)
+	op.GeoM.Translate(x, y)
+	if t.Enemy && t.Type == sim.ArmorTank {
+		tintArmor(op, t.HP)
+	}
 	screen.DrawImage(img, op)
-	width, height, x, y := t.GetInfo()
+	width, height := t.Width, t.Height
 	vector.DrawFilledRect(screen, float32(x), float32(y), float32(width), float32(height), color.RGBA{255, 0, 0, 20}, true)
+	// The shield flashes every few ticks.
+	if t.Shield > 0 && t.Shield/4%2 == 0 {
+		vector.StrokeRect(screen, float32(x)-2, float32(y)-2, float32(width)+4, float32(height)+4, 2, color.White, false)
+	}
+}
+
+// tintArmor colors an armor tank by the armor it has left: green when it is
+// fresh, then yellow, then white, and its plain sprite on the last hit point.
+func tintArmor(op *ebiten.DrawImageOptions, hp int) {
+	switch hp {
+	case 4:
+		op.ColorScale.Scale(0.5, 1, 0.5, 1)
+	case 3:
+		op.ColorScale.Scale(1, 1, 0.4, 1)
+	case 2:
+		op.ColorScale.Scale(1.2, 1.2, 1.2, 1)
+	}
 }
 
 func (g *Game) DrawCastle(screen *ebiten.Image) {
-	c := g.castle
+	c := g.world.Castle
 	var img *ebiten.Image
-	if c.mode == 0 {
-		img = c.Image
-	} else if c.mode == 2 {
-		img = c.DestroyedImage
+	if c.Mode == 0 {
+		img = castleImage
+	} else {
+		img = destroyedCastleImage
 	}
 	op := &ebiten.DrawImageOptions{}
 	// w, h := img.Bounds().Dx(), img.Bounds().Dy()
 	// op.GeoM.Translate(float64(w)/2, float64(h)/2)
 	op.GeoM.Scale(2, 2)
-	op.GeoM.Translate(192, 384)
+	op.GeoM.Translate(c.X, c.Y)
 	screen.DrawImage(img, op)
-}
-
-func (g *Game) DrawOther(screen *ebiten.Image) {
-	op := &ebiten.DrawImageOptions{}
-	for other := range g.others {
-		op.GeoM.Reset()
-		op.GeoM.Scale(2, 2)
-		op.GeoM.Translate(other.X, other.Y)
-		screen.DrawImage(other.Image, op)
-		width, height, x, y := other.GetInfo()
-		vector.DrawFilledRect(screen, float32(x), float32(y), float32(width), float32(height), color.RGBA{255, 0, 0, 30}, true)
+	if c.Mode == 1 {
+		g.drawExplosion(screen, c, c.Explosion())
 	}
 }
 
-func (g *Game) Move(t *Tank) bool {
-	// var x, y float64
-	if t.Failed {
-		return false
+// drawExplosion draws the explosion frame reached at progress, from 0 to 1,
+// centered on e.
+func (g *Game) drawExplosion(screen *ebiten.Image, e sim.Entity, progress float64) {
+	i := int(progress * float64(len(explosionImages)))
+	if i >= len(explosionImages) {
+		i = len(explosionImages) - 1
 	}
-	x0, y0 := t.X, t.Y
-	switch t.Face {
-	case 0:
-		t.Y -= t.SpeedFactor
-	case 1:
-		t.X += t.SpeedFactor
-	case 2:
-		t.Y += t.SpeedFactor
-	default:
-		t.X -= t.SpeedFactor
-	}
-
-	if t != g.p0 && !g.p0.Failed {
-		if CheckCollision(g.p0, t, true) {
-			t.X, t.Y = x0, y0
-			return true
-		}
-	}
-	if g.p1 != nil && t != g.p1 && !g.p1.Failed {
-		if CheckCollision(g.p1, t, true) {
-			t.X, t.Y = x0, y0
-			return true
-		}
-	}
-
-	for e := range g.enemys {
-		if e != t && CheckCollision(e, t, true) {
-			t.X, t.Y = x0, y0
-			return true
-		}
-	}
-
-	for other := range g.others {
-		if CheckCollision(t, other, true) {
-			t.X, t.Y = x0, y0
-			return true
-		}
-	}
-
-	if g.OutOfScreen(t) {
-		t.X, t.Y = x0, y0
-		return true
-	}
-	return false
-
+	img := explosionImages[i]
+	width, height, x, y := e.GetInfo()
+	w, h := img.Bounds().Dx()*2, img.Bounds().Dy()*2
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Scale(2, 2)
+	op.GeoM.Translate(x+float64(width-w)/2, y+float64(height-h)/2)
+	screen.DrawImage(img, op)
 }
 
-func (g *Game) EnemyMove(t *Tank) {
-	// var x, y float64
-	if t.Failed {
-		return
-	}
-	switch t.Face {
-	case 0:
-		t.Y -= t.SpeedFactor
-	case 1:
-		t.X += t.SpeedFactor
-	case 2:
-		t.Y += t.SpeedFactor
-	default:
-		t.X -= t.SpeedFactor
+// drawChipped draws only the cells of a tile that haven't been shot away.
+func drawChipped(screen *ebiten.Image, o *sim.Other) {
+	img := otherImage(o)
+	b := img.Bounds()
+	const cell = sim.CellSize / 2 // in sprite pixels
+	op := &ebiten.DrawImageOptions{}
+	for r := 0; r < sim.CellsPerSide; r++ {
+		for c := 0; c < sim.CellsPerSide; c++ {
+			if !o.Cell(r, c) {
+				continue
+			}
+			x, y := b.Min.X+c*cell, b.Min.Y+r*cell
+			op.GeoM.Reset()
+			op.GeoM.Scale(2, 2)
+			op.GeoM.Translate(o.X+float64(c*sim.CellSize), o.Y+float64(r*sim.CellSize))
+			screen.DrawImage(img.SubImage(image.Rect(x, y, x+cell, y+cell)).(*ebiten.Image), op)
+		}
 	}
 }
 
-func (g *Game) HitAndRemove(b *Bullet) {
-	if !g.p0.Failed && b.Owner != g.p0 {
-		if CheckCollision(g.p0, b, false) {
-			g.p0.Failed = true
-			delete(g.bullets, b)
-			return
-		}
-	}
-	if g.p1 != nil && !g.p1.Failed && b.Owner != g.p1 {
-		if CheckCollision(g.p1, b, false) {
-			g.p1.Failed = true
-			delete(g.bullets, b)
-			return
-		}
-	}
-	for other := range g.others {
-		if CheckCollision(other, b, false) {
-			delete(g.others, other)
-			delete(g.bullets, b)
-			return
-		}
-	}
-	for e := range g.enemys {
-		if b.Owner != e && CheckCollision(e, b, false) {
-			delete(g.enemys, e)
-			delete(g.bullets, b)
-			return
+// DrawOther draws grass over the tanks, so they can hide under it.
+func (g *Game) DrawOther(screen *ebiten.Image) {
+	op := &ebiten.DrawImageOptions{}
+	for _, other := range g.world.Others {
+		if other.T == sim.Grass {
+			op.GeoM.Reset()
+			op.GeoM.Scale(2, 2)
+			op.GeoM.Translate(other.X, other.Y)
+			screen.DrawImage(otherImage(other), op)
 		}
+		width, height, x, y := other.GetInfo()
+		vector.DrawFilledRect(screen, float32(x), float32(y), float32(width), float32(height), color.RGBA{255, 0, 0, 30}, true)
 	}
 }
 
 func (g *Game) DrawBullet(screen *ebiten.Image) {
 	op := &ebiten.DrawImageOptions{}
-	for bullet := range g.bullets {
+	for _, bullet := range g.world.Bullets {
 		op.GeoM.Reset()
-		img := bullet.Image
+		img := bulletImage
 		w, h := img.Bounds().Dx(), img.Bounds().Dy()
 		op.GeoM.Translate(float64(-w)/2, float64(-h)/2)
 		angle := float64(bullet.F) * (math.Pi / 2)
 		op.GeoM.Rotate(angle)
 		op.GeoM.Translate(float64(w)/2, float64(h)/2)
+		x, y := bullet.Lerp(g.alpha())
 		op.GeoM.Scale(2, 2)
-		op.GeoM.Translate(bullet.X, bullet.Y)
+		op.GeoM.Translate(x, y)
 		screen.DrawImage(img, op)
 		// ebitenutil.DrawRect(screen, 100, 100, 200, 100, color.RGBA{255, 0, 0, 255})
-		width, height, x, y := bullet.GetInfo()
+		width, height := bullet.Width, bullet.Height
 		vector.DrawFilledRect(screen, float32(x), float32(y), float32(width), float32(height), color.RGBA{255, 0, 0, 255}, false)
 	}
 	// w, h := img.Bounds().Dx(), img.Bounds().Dy()
 	// op.GeoM.Translate(float64(w)/2, float64(h)/2)
 }
 
-func (g *Game) addBullet(bullet *Bullet) {
-	g.bullets[bullet] = struct{}{}
-}
-
-func (g *Game) addOther(o *Other) {
-	g.others[o] = struct{}{}
-}
-
-func (g *Game) addEnermy(enemy *Tank) {
-	g.enemys[enemy] = struct{}{}
-}
-
-func NotSafe(t1, t2 *Tank) bool {
-	w1, h1, x_1, y_1 := t1.GetInfo()
-	x1, y1 := x_1+float64(w1)/2, y_1+float64(h1)/2
-	w2, h2, x_2, y_2 := t2.GetInfo()
-	x2, y2 := x_2+float64(w2)/2, y_2+float64(h2)/2
-	if math.Pow(x1-x2, 2)+math.Pow(y1-y2, 2) <= math.Pow(float64(w1), 2)+math.Pow(float64(h1), 2) {
-		return true
-	}
-	return false
-
-}
-
-func (g *Game) PosConflict(enemy *Tank) bool {
-	if !g.p0.Failed && NotSafe(g.p0, enemy) {
-		return true
+// step advances the world by one tick.
+func (g *Game) step() {
+	if g.replay != nil && g.world.Tick == g.replay.Len() {
+		log.Print(g.world.Outcome())
+		g.mode = ModeGameOver
+		return
 	}
-
-	if g.p1 != nil && !g.p1.Failed && NotSafe(g.p0, enemy) {
-		return true
+	inputs := sim.Poll(g.sources)
+	if g.recording != nil {
+		g.recording.Record(inputs)
 	}
-
-	for e := range g.enemys {
-		if NotSafe(e, enemy) {
-			return true
-		}
+	g.world.Step(inputs)
+	if g.editor != nil && (g.world.State == sim.GameOver || g.world.State == sim.StageClear) {
+		g.mode = ModeEditor
+		return
 	}
-	return false
-
-}
-
-func (g *Game) Generate_enemy() {
-	if g.idx < 20 {
-		// f := rand.Intn(4)
-		pos := born_positions[rand.Intn(3)]
-		x, y := float64(pos[0]), float64(pos[1])
-		e := NewEnemy(EnemyType(g.enemies_left[g.idx]), rand.Intn(4), x, y)
-		if g.PosConflict(e) || len(g.enemys) == max_enemies {
-			return
-		}
-		g.addEnermy(e)
-		g.idx++
+	if g.world.State == sim.GameOver {
+		log.Print(g.world.Outcome())
+		g.mode = ModeGameOver
 	}
 }
 
-func (g *Game) OutOfScreen(e Entity) bool {
-	w, h, x, y := e.GetInfo()
-	x1, y1 := float64(w)+x, float64(h)+y
-	if x < 0 || y < 0 {
-		return true
+// updateRate is how many times per second Update is called.
+func updateRate() int {
+	if tps := ebiten.TPS(); tps > 0 {
+		return tps
 	}
-	if x1 > screenWidth || y1 > screenHeight {
-		return true
+	// Synced with the display instead, go by the frame rate.
+	if fps := ebiten.ActualFPS(); fps > 0 {
+		return int(math.Round(fps))
 	}
-	return false
+	return sim.TickRate
+}
 
+// alpha is how far, from 0 to 1, the game is between the last tick and the
+// next, for drawing things that move between where they were and where they are.
+func (g *Game) alpha() float64 {
+	return g.lag * sim.TickRate
 }
 
-func (g *Game) init() {
-	// g.players = make(map[*Tank]struct{})
-	g.enemys = make(map[*Tank]struct{})
-	g.bullets = make(map[*Bullet]struct{})
-	g.others = make(map[*Other]struct{})
-	g.enemies_left = []int{}
-	for i, v := range levels_enemies[g.level-1] {
-		for k := 0; k < v; k++ {
-			g.enemies_left = append(g.enemies_left, i)
+func (g *Game) init() error {
+	g.lag = 0
+	if g.replay != nil {
+		w, err := g.replay.NewWorld(g.levels)
+		if err != nil {
+			return err
 		}
+		g.world = w
+		g.sources = g.replay.Sources()
+		return nil
+	}
+	g.world = sim.NewWorld(g.levels, 1, g.twoPlayer, g.seed)
+	g.world.SetRules(g.rules)
+	g.recording = sim.NewReplay(g.seed, 1, g.twoPlayer)
+	g.recording.Rules = &g.rules
+	g.sources = g.playerSources()
+	return nil
+}
+
+func (g *Game) playerSources() [2]sim.InputSource {
+	sources := [2]sim.InputSource{playerInput(0), sim.NoInput{}}
+	if g.twoPlayer {
+		sources[1] = playerInput(1)
 	}
-	rand.Shuffle(len(g.enemies_left), func(i, j int) {
-		g.enemies_left[i], g.enemies_left[j] = g.enemies_left[j], g.enemies_left[i]
-	})
-	g.idx = 0
-	g.addPlayer()
-	g.mapLevel = GetLevel(g.level)
-	g.ParseLevel()
+	return sources
 }
 
-func NewGame() *Game {
+func NewGame(seed int64, rules sim.Rules, levels []*sim.Level, editFile string, replay *sim.Replay) (*Game, error) {
 	ebiten.SetWindowSize(screenWidth, screenHeight)
 	ebiten.SetWindowTitle("Battle City")
 
 	game := &Game{
-		mode:      ModeTitle,
-		twoPlayer: false,
-		castle:    NewCastle(),
-		level:     1,
+		mode:       ModeTitle,
+		twoPlayer:  false,
+		seed:       seed,
+		rules:      rules,
+		levels:     levels,
+		editFile:   editFile,
+		replay:     replay,
+		highScores: LoadHighScores(),
+	}
+	if replay != nil {
+		game.mode = ModeGame
+		if err := game.init(); err != nil {
+			return nil, err
+		}
 	}
-	return game
+	return game, nil
 }
 
 type Caption struct {
@@ -679,34 +464,10 @@ type Caption struct {
 	y    int
 }
 
-func (g *Game) ParseLevel() {
-	for i, s := range g.mapLevel {
-		for j := 0; j < len(s); j++ {
-			ch := s[j]
-			x, y := float64(j*tileSize), float64(i*tileSize)
-			var o *Other
-			switch ch {
-			case '#': //brick
-				o = NewOther(x, y, 0)
-			case '@': //steel
-				o = NewOther(x, y, 1)
-			case '%': //water
-				o = NewOther(x, y, 2)
-			case '~': //grass
-				o = NewOther(x, y, 3)
-			default:
-			}
-			if ch != '.' {
-				g.addOther(o)
-			}
-		}
-	}
-}
-
 func (g *Game) DrawIntroScreen(screen *ebiten.Image) {
 	screen.Fill(color.RGBA{0x0, 0x0, 0x0, 0xff})
 	cap1 := Caption{
-		text: string("HI-"),
+		text: fmt.Sprintf("HI- %d", g.highScores.Top()),
 		x:    170 / 2,
 		y:    135 / 2,
 	}
@@ -720,6 +481,11 @@ func (g *Game) DrawIntroScreen(screen *ebiten.Image) {
 		x:    165 / 2,
 		y:    275 / 2,
 	}
+	cap6 := Caption{
+		text: string("CONSTRUCTION"),
+		x:    165 / 2,
+		y:    300 / 2,
+	}
 	cap4 := Caption{
 		text: string("(c) 1980 1985 NAMCO LTD."),
 		x:    50 / 2,
@@ -730,214 +496,273 @@ func (g *Game) DrawIntroScreen(screen *ebiten.Image) {
 		x:    85 / 2,
 		y:    380 / 2,
 	}
-	var captions = [5]Caption{cap1, cap2, cap3, cap4, cap5}
+	var captions = [6]Caption{cap1, cap2, cap3, cap4, cap5, cap6}
 	for _, cap := range captions {
 		text.Draw(screen, cap.text, smallArcadeFont, cap.x, cap.y, color.White)
 	}
+	cursor := [menuEntries]Caption{cap2, cap3, cap6}[g.menu]
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Rotate(math.Pi / 2)
+	op.GeoM.Translate(float64(cursor.x-4), float64(cursor.y-12))
+	screen.DrawImage(player1Image, op)
 
 }
 
-func (g *Game) GetDirection(t *Tank) int {
-	var directions [4]int
-	cur_dir := t.Face
-	dir0 := (t.Face + 1) % 4
-	oppo_dir := (t.Face + 2) % 4
-	dir1 := (t.Face + 3) % 4
-
-	r := rand.Intn(2)
-	if r == 0 {
-		directions = [4]int{cur_dir, dir0, dir1, oppo_dir}
-	} else {
-		directions = [4]int{cur_dir, dir1, dir0, oppo_dir}
-	}
-
-	w0, h0, x0, y0 := t.GetInfo()
-	var x, y float64
-	var collid bool
-	var W, H int
-	var X, Y float64
-	for _, dir := range directions {
-		switch dir {
-		case 0:
-			y = y0 - t.SpeedFactor
-		case 1:
-			x = x0 + t.SpeedFactor
-		case 2:
-			y = y0 + t.SpeedFactor
-		case 3:
-			x = x0 - t.SpeedFactor
+func (g *Game) Update() error {
+	switch g.mode {
+	case ModeTitle:
+		if inpututil.IsKeyJustPressed(ebiten.KeyUp) {
+			g.menu = (g.menu + menuEntries - 1) % menuEntries
 		}
-
-		collid = false
-		if t != g.p0 && !g.p0.Failed {
-			W, H, X, Y = g.p0.GetInfo()
-			if RectCollision(W, H, X, Y, w0, h0, x, y, true) {
-				collid = true
+		if inpututil.IsKeyJustPressed(ebiten.KeyDown) {
+			g.menu = (g.menu + 1) % menuEntries
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeySpace) && g.menu == menuConstruction {
+			e, err := newEditor(g.editFile)
+			if err != nil {
+				log.Printf("construction: %v", err)
+				return nil
 			}
+			g.editor = e
+			g.mode = ModeEditor
+		} else if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
+			g.twoPlayer = g.menu == menuTwoPlayers
+			g.mode = ModeGame
+			if err := g.init(); err != nil {
+				return err
+			}
+		}
+	case ModeGame:
+		if g.editor != nil && inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+			g.mode = ModeEditor
+			return nil
 		}
-		if g.p1 != nil && t != g.p1 && !g.p1.Failed {
-			W, H, X, Y = g.p1.GetInfo()
-			if RectCollision(W, H, X, Y, w0, h0, x, y, true) {
-				collid = true
+		for _, s := range g.sources {
+			if l, ok := s.(*latchedInput); ok {
+				l.Read()
 			}
 		}
+		// The world steps at its own tick rate, whatever ours is.
+		g.lag += 1 / float64(updateRate())
+		for g.lag >= 1.0/sim.TickRate && g.mode == ModeGame {
+			g.lag -= 1.0 / sim.TickRate
+			g.step()
+		}
 
-		for e := range g.enemys {
-			W, H, X, Y = e.GetInfo()
-			if e != t && RectCollision(W, H, X, Y, w0, h0, x, y, true) {
-				collid = true
+	case ModeGameOver:
+		if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
+			if g.replay != nil {
+				g.mode = ModeTitle
+				g.replay = nil
+				return nil
+			}
+			g.pending = []*sim.Tank{g.world.P0}
+			if g.world.P1 != nil {
+				g.pending = append(g.pending, g.world.P1)
 			}
+			g.nextInitials()
 		}
 
-		for other := range g.others {
-			W, H, X, Y = other.GetInfo()
-			if RectCollision(W, H, X, Y, w0, h0, x, y, true) {
-				collid = true
-			}
+	case ModeEnterInitials:
+		g.updateInitials()
+
+	case ModeHighScores:
+		if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
+			g.mode = ModeTitle
 		}
 
-		if x < 0 || y < 0 || x+float64(w0) > screenWidth || y+float64(h0) > screenHeight {
-			collid = true
+	case ModeEditor:
+		g.updateEditor()
+	}
+	return nil
+}
+
+// nextInitials asks the next pending player that made the table for their
+// initials, or shows the table once nobody is left.
+func (g *Game) nextInitials() {
+	for len(g.pending) > 0 && !g.highScores.Qualifies(g.pending[0].Score) {
+		g.pending = g.pending[1:]
+	}
+	if len(g.pending) == 0 {
+		g.mode = ModeHighScores
+		return
+	}
+	g.initials = g.initials[:0]
+	g.mode = ModeEnterInitials
+}
+
+func (g *Game) updateInitials() {
+	for _, r := range ebiten.AppendInputChars(nil) {
+		r = unicode.ToUpper(r)
+		if len(g.initials) < 3 && (r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			g.initials = append(g.initials, r)
 		}
-		if !collid {
-			return dir
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyBackspace) && len(g.initials) > 0 {
+		g.initials = g.initials[:len(g.initials)-1]
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) && len(g.initials) > 0 {
+		g.highScores.Add(HighScore{
+			Initials: string(g.initials),
+			Score:    g.pending[0].Score,
+			Level:    g.world.Level,
+			Date:     time.Now(),
+		})
+		if err := g.highScores.Save(); err != nil {
+			log.Printf("high scores: %v", err)
 		}
+		g.pending = g.pending[1:]
+		g.nextInitials()
 	}
-	return -1
 }
 
-func (g *Game) Update() error {
+func (g *Game) Draw(screen *ebiten.Image) {
 	switch g.mode {
 	case ModeTitle:
-		if inpututil.IsKeyJustPressed(ebiten.KeyUp) || inpututil.IsKeyJustPressed(ebiten.KeyDown) {
-			g.twoPlayer = !g.twoPlayer
-		}
-		if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
-			g.mode = ModeGame
-			g.init()
-		}
+		g.DrawIntroScreen(screen)
+
 	case ModeGame:
-		for bullet := range g.bullets {
-			if bullet.X <= 0 || bullet.X >= screenWidth || bullet.Y <= 0 || bullet.Y >= screenHeight {
-				delete(g.bullets, bullet)
-			} else {
-				bullet.Move()
-				g.HitAndRemove(bullet)
-			}
+		if g.world.State == sim.StageClear {
+			g.DrawStageClear(screen)
+			return
 		}
+		g.DrawField(screen)
 
-		for e := range g.enemys {
-			dir := g.GetDirection(e)
-			if dir == -1 {
-				e.Face = (e.Face + 2) % 2
-			} else if dir == e.Face {
-				// g.EnemyMove(e)
-				g.Move(e)
-			} else {
-				e.Face = dir
-			}
-		}
+	case ModeGameOver:
+		g.DrawField(screen)
+		g.DrawGameOver(screen)
 
-		g.Generate_enemy()
-
-		if !g.p0.Failed {
-			if ebiten.IsKeyPressed(ebiten.KeyW) {
-				if g.p0.Face == 0 {
-					// g.p0.Y -= g.p0.SpeedFactor
-					g.Move(g.p0)
-				} else {
-					g.p0.Face = 0
-				}
-			} else if ebiten.IsKeyPressed(ebiten.KeyD) {
-				if g.p0.Face == 1 {
-					// g.p0.X += g.p0.SpeedFactor
-					g.Move(g.p0)
-				} else {
-					g.p0.Face = 1
-				}
-			} else if ebiten.IsKeyPressed(ebiten.KeyS) {
-				if g.p0.Face == 2 {
-					// g.p0.Y += g.p0.SpeedFactor
-					g.Move(g.p0)
-				} else {
-					g.p0.Face = 2
-				}
-			} else if ebiten.IsKeyPressed(ebiten.KeyA) {
-				if g.p0.Face == 3 {
-					// g.p0.X -= g.p0.SpeedFactor
-					g.Move(g.p0)
-				} else {
-					g.p0.Face = 3
-				}
-			} else if inpututil.IsKeyJustPressed(ebiten.KeyF) {
-				b := g.p0.Fire()
-				g.addBullet(b)
-			}
-		}
-		if g.p1 != nil && !g.p1.Failed {
-			if ebiten.IsKeyPressed(ebiten.KeyUp) {
-				if g.p1.Face == 0 {
-					// g.p1.Y -= g.p1.SpeedFactor
-					g.Move(g.p1)
-				} else {
-					g.p1.Face = 0
-				}
-			} else if ebiten.IsKeyPressed(ebiten.KeyRight) {
-				if g.p1.Face == 1 {
-					// g.p1.X += g.p1.SpeedFactor
-					g.Move(g.p1)
-				} else {
-					g.p1.Face = 1
-				}
-			} else if ebiten.IsKeyPressed(ebiten.KeyDown) {
-				if g.p1.Face == 2 {
-					// g.p1.Y += g.p1.SpeedFactor
-					g.Move(g.p1)
-				} else {
-					g.p1.Face = 2
-				}
-			} else if ebiten.IsKeyPressed(ebiten.KeyLeft) {
-				if g.p1.Face == 3 {
-					// g.p1.X -= g.p1.SpeedFactor
-					g.Move(g.p1)
-				} else {
-					g.p1.Face = 3
-				}
-			} else if inpututil.IsKeyJustPressed(ebiten.KeyControlRight) {
-				b := g.p1.Fire()
-				g.addBullet(b)
-			}
-		}
+	case ModeEnterInitials:
+		g.DrawEnterInitials(screen)
 
-	case ModeGameOver:
+	case ModeHighScores:
+		g.DrawHighScores(screen)
+
+	case ModeEditor:
+		g.DrawEditor(screen)
 	}
-	return nil
 }
 
-func (g *Game) Draw(screen *ebiten.Image) {
-	switch g.mode {
-	case ModeTitle:
-		g.DrawIntroScreen(screen)
+func (g *Game) DrawEnterInitials(screen *ebiten.Image) {
+	screen.Fill(color.Black)
+	player := "I-PLAYER"
+	if g.pending[0] == g.world.P1 {
+		player = "II-PLAYER"
+	}
+	drawCentered(screen, "NEW HIGH SCORE", smallArcadeFont, 120, color.RGBA{0xd8, 0x28, 0x00, 0xff})
+	drawCentered(screen, fmt.Sprintf("%s %d", player, g.pending[0].Score), smallArcadeFont, 160, color.White)
+	name := string(g.initials)
+	for len(name) < 3 {
+		name += "_"
+	}
+	drawCentered(screen, name, arcadeFont, 220, color.White)
+	drawCentered(screen, "TYPE INITIALS", smallArcadeFont, 280, color.White)
+	drawCentered(screen, "AND PRESS ENTER", smallArcadeFont, 300, color.White)
+}
 
-	case ModeGame:
-		op := &ebiten.DrawImageOptions{}
-		for brick := range g.others {
-			op.GeoM.Reset()
-			op.GeoM.Scale(2, 2)
-			op.GeoM.Translate(brick.X, brick.Y)
-			screen.DrawImage(brick.Image, op)
+func (g *Game) DrawHighScores(screen *ebiten.Image) {
+	screen.Fill(color.Black)
+	drawCentered(screen, "HIGH SCORES", smallArcadeFont, 50, color.RGBA{0xd8, 0x28, 0x00, 0xff})
+	for i, e := range g.highScores {
+		line := fmt.Sprintf("%2d %-3s %7d %2d %s", i+1, e.Initials, e.Score, e.Level, e.Date.Format("06-01-02"))
+		text.Draw(screen, line, smallArcadeFont, 22, 90+i*24, color.White)
+	}
+	drawCentered(screen, "PRESS SPACE", smallArcadeFont, 380, color.White)
+}
+
+func (g *Game) DrawField(screen *ebiten.Image) {
+	op := &ebiten.DrawImageOptions{}
+	for _, brick := range g.world.Others {
+		if brick.T == sim.Grass {
+			continue
 		}
-		g.drawTank(g.p0, screen)
-		g.drawTank(g.p1, screen)
-		for e := range g.enemys {
-			g.drawTank(e, screen)
+		if !brick.Whole() {
+			drawChipped(screen, brick)
+			continue
 		}
-		g.DrawCastle(screen)
-		g.DrawBullet(screen)
-		g.DrawOther(screen)
+		op.GeoM.Reset()
+		op.GeoM.Scale(2, 2)
+		op.GeoM.Translate(brick.X, brick.Y)
+		screen.DrawImage(otherImage(brick), op)
+	}
+	g.drawTank(g.world.P0, screen)
+	g.drawTank(g.world.P1, screen)
+	for _, e := range g.world.Enemys {
+		g.drawTank(e, screen)
+	}
+	g.DrawCastle(screen)
+	g.DrawBullet(screen)
+	g.DrawOther(screen)
+	g.DrawPowerUp(screen)
+}
 
-	case ModeGameOver:
+func (g *Game) DrawPowerUp(screen *ebiten.Image) {
+	p := g.world.PowerUp
+	// Power-ups blink so they stand out from the terrain.
+	if p == nil || g.world.Tick/16%2 == 1 {
+		return
+	}
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Scale(2, 2)
+	op.GeoM.Translate(p.X, p.Y)
+	screen.DrawImage(powerUpImages[p.T], op)
+}
+
+// drawCentered draws s horizontally centered with its baseline at y.
+func drawCentered(screen *ebiten.Image, s string, face font.Face, y int, clr color.Color) {
+	b := text.BoundString(face, s)
+	text.Draw(screen, s, face, (screenWidth-b.Dx())/2, y, clr)
+}
+
+// DrawStageClear shows the tally of the finished stage: kills and points per
+// enemy type for each player, like the arcade original.
+func (g *Game) DrawStageClear(screen *ebiten.Image) {
+	w := g.world
+	screen.Fill(color.Black)
+	drawCentered(screen, fmt.Sprintf("STAGE %d", w.Level), smallArcadeFont, 40, color.White)
+
+	players := [2]*sim.Tank{w.P0, w.P1}
+	red := color.RGBA{0xd8, 0x28, 0x00, 0xff}
+	orange := color.RGBA{0xfc, 0x98, 0x38, 0xff}
+	text.Draw(screen, "I-PLAYER", smallArcadeFont, 40, 80, red)
+	text.Draw(screen, fmt.Sprintf("%8d", w.P0.Score), smallArcadeFont, 40, 100, orange)
+	if w.P1 != nil {
+		text.Draw(screen, "II-PLAYER", smallArcadeFont, 268, 80, red)
+		text.Draw(screen, fmt.Sprintf("%8d", w.P1.Score), smallArcadeFont, 268, 100, orange)
+	}
+
+	icons := [sim.EnemyTypes]*ebiten.Image{enemy1Image, enemy2Image, enemy3Image, enemy4Image}
+	var totals [2]int
+	for i, icon := range icons {
+		y := 150 + i*40
+		t := sim.EnemyType(i)
+		op := &ebiten.DrawImageOptions{}
+		op.GeoM.Scale(2, 2)
+		op.GeoM.Translate(195, float64(y-22))
+		screen.DrawImage(icon, op)
+		for p, player := range players {
+			if player == nil {
+				continue
+			}
+			kills := player.Kills[t]
+			totals[p] += kills
+			if p == 0 {
+				text.Draw(screen, fmt.Sprintf("%4d PTS %2d<", kills*t.Points(), kills), smallArcadeFont, 40, y, color.White)
+			} else {
+				text.Draw(screen, fmt.Sprintf(">%2d %4d PTS", kills, kills*t.Points()), smallArcadeFont, 232, y, color.White)
+			}
+		}
 	}
+	vector.StrokeLine(screen, 136, 320, 280, 320, 2, color.White, false)
+	text.Draw(screen, fmt.Sprintf("TOTAL %2d", totals[0]), smallArcadeFont, 64, 345, color.White)
+	if w.P1 != nil {
+		text.Draw(screen, fmt.Sprintf("%2d TOTAL", totals[1]), smallArcadeFont, 244, 345, color.White)
+	}
+}
+
+func (g *Game) DrawGameOver(screen *ebiten.Image) {
+	drawCentered(screen, "GAME OVER", arcadeFont, 210, color.RGBA{0xd8, 0x28, 0x00, 0xff})
+	drawCentered(screen, "PRESS SPACE", smallArcadeFont, 240, color.White)
 }
 
 func (g *Game) Layout(outsideWidth, outsideHeight int) (int, int) {
@@ -945,9 +770,57 @@ func (g *Game) Layout(outsideWidth, outsideHeight int) (int, int) {
 }
 
 func main() {
-	g := NewGame()
+	if len(os.Args) > 1 && os.Args[1] == "validate-levels" {
+		os.Exit(validateLevels(os.Args[2:]))
+	}
+
+	seed := flag.Int64("seed", 0, "seed for gameplay randomness, 0 picks one from the clock")
+	record := flag.String("record", "", "save the inputs of the match to this replay file")
+	replayFile := flag.String("replay", "", "play back a replay file instead of reading input")
+	versus := flag.Bool("versus", false, "put the two players on opposing teams")
+	friendlyFire := flag.String("friendly-fire", sim.ArcadeRules.PlayerFire.String(), "what a player's bullet does to a teammate: off, freeze or on")
+	levelsPath := flag.String("levels", "", "directory or .zip of level files that replace embedded levels of the same name or add to them")
+	snap := flag.Float64("snap", sim.ArcadeRules.PlayerSnap, "how many pixels a turning player may be pulled onto the tile grid, 0 turns in place")
+	enemySnap := flag.Float64("enemy-snap", sim.ArcadeRules.EnemySnap, "how many pixels a turning enemy may be pulled onto the tile grid, 0 turns in place")
+	editFile := flag.String("edit", "level", "level file construction mode opens and saves")
+	flag.Parse()
+
+	rules := sim.ArcadeRules
+	rules.Versus = *versus
+	rules.PlayerSnap = *snap
+	rules.EnemySnap = *enemySnap
+	var err error
+	if rules.PlayerFire, err = sim.ParseFriendlyFire(*friendlyFire); err != nil {
+		log.Fatal(err)
+	}
+
+	levels, err := sim.LoadLevelsFrom(*levelsPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var replay *sim.Replay
+	if *replayFile != "" {
+		replay, err = sim.LoadReplay(*replayFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	log.Printf("seed: %d", *seed)
+	g, err := NewGame(*seed, rules, levels, *editFile, replay)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	if err := ebiten.RunGame(g); err != nil {
 		log.Fatal(err)
 	}
+	if *record != "" && g.recording != nil {
+		if err := g.recording.Save(*record); err != nil {
+			log.Fatal(err)
+		}
+	}
 }
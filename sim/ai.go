@@ -0,0 +1,73 @@
+package sim
+
+// enemyFire decides whether e shoots this tick. An enemy fires as soon as its
+// class cooldown allows when it sees a player or the castle straight ahead,
+// and otherwise at random as often as its class does, never with more bullets
+// of its own on the field than it is allowed.
+func (w *World) enemyFire(e *Tank) {
+	if e.Cooldown > 0 {
+		e.Cooldown--
+		return
+	}
+	if w.bulletsOf(e) >= e.MaxBullets() {
+		return
+	}
+	if !w.targetInSight(e) && w.rng.Intn(e.Type.Stats().FireChance) != 0 {
+		return
+	}
+	w.addBullet(e.Fire())
+	e.Cooldown = e.Type.Stats().FireCooldown
+}
+
+func (w *World) targetInSight(e *Tank) bool {
+	for _, p := range [2]*Tank{w.P0, w.P1} {
+		if p != nil && !p.Failed && w.inSight(e, p) {
+			return true
+		}
+	}
+	return w.Castle.Mode == 0 && w.inSight(e, w.Castle)
+}
+
+// inSight reports whether target lies straight ahead of t with no steel in
+// between. Bricks don't count as cover, shooting through them is the point.
+func (w *World) inSight(t *Tank, target Entity) bool {
+	width, height, x, y := t.GetInfo()
+	tw, th := float64(width), float64(height)
+	width, height, gx, gy := target.GetInfo()
+	gw, gh := float64(width), float64(height)
+	// The lane runs from the front of t to the near side of target and is as
+	// wide as t, which is roughly where its bullets fly.
+	var lx, ly, lw, lh float64
+	switch t.Face {
+	case 0:
+		if gx+gw <= x || gx >= x+tw || gy+gh > y {
+			return false
+		}
+		lx, ly, lw, lh = x, gy+gh, tw, y-(gy+gh)
+	case 1:
+		if gy+gh <= y || gy >= y+th || gx < x+tw {
+			return false
+		}
+		lx, ly, lw, lh = x+tw, y, gx-(x+tw), th
+	case 2:
+		if gx+gw <= x || gx >= x+tw || gy < y+th {
+			return false
+		}
+		lx, ly, lw, lh = x, y+th, tw, gy-(y+th)
+	default:
+		if gy+gh <= y || gy >= y+th || gx+gw > x {
+			return false
+		}
+		lx, ly, lw, lh = gx+gw, y, x-(gx+gw), th
+	}
+	for _, o := range w.Grid.Tiles(lx, ly, lw, lh) {
+		if o.T != Steel {
+			continue
+		}
+		width, height, ox, oy := o.GetInfo()
+		if overlaps(lx, ly, lw, lh, ox, oy, float64(width), float64(height)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,85 @@
+package sim
+
+import "math"
+
+// Rect is an axis aligned box in screen pixels.
+type Rect struct {
+	X, Y, W, H float64
+}
+
+// Bounds is the box e takes up on the field.
+func Bounds(e Entity) Rect {
+	width, height, x, y := e.GetInfo()
+	return Rect{x, y, float64(width), float64(height)}
+}
+
+// Overlaps reports whether r and o share any area.
+func (r Rect) Overlaps(o Rect) bool {
+	return overlaps(r.X, r.Y, r.W, r.H, o.X, o.Y, o.W, o.H)
+}
+
+// Touches reports whether r and o overlap or share part of an edge.
+func (r Rect) Touches(o Rect) bool {
+	return r.X <= o.X+o.W && o.X <= r.X+r.W && r.Y <= o.Y+o.H && o.Y <= r.Y+r.H
+}
+
+// Union is the smallest box holding both r and o.
+func (r Rect) Union(o Rect) Rect {
+	x, y := math.Min(r.X, o.X), math.Min(r.Y, o.Y)
+	return Rect{x, y, math.Max(r.X+r.W, o.X+o.W) - x, math.Max(r.Y+r.H, o.Y+o.H) - y}
+}
+
+// Moved is r shifted by dx, dy.
+func (r Rect) Moved(dx, dy float64) Rect {
+	return Rect{r.X + dx, r.Y + dy, r.W, r.H}
+}
+
+// Sweep reports whether r moving by dx, dy comes to overlap o, and how far
+// along the move, from 0 to 1, that first happens. Unlike testing where r
+// ends up, it can't miss o by jumping over it.
+func (r Rect) Sweep(dx, dy float64, o Rect) (float64, bool) {
+	enter, exit := 0.0, 1.0
+	if !slab(r.X, r.W, dx, o.X, o.W, &enter, &exit) || !slab(r.Y, r.H, dy, o.Y, o.H, &enter, &exit) {
+		return 0, false
+	}
+	return enter, true
+}
+
+// slab narrows [enter, exit] down to the part of the move during which the
+// segment at p of length size, moving by d, overlaps the one at q of length
+// qsize, and reports whether anything is left.
+func slab(p, size, d, q, qsize float64, enter, exit *float64) bool {
+	if d == 0 {
+		return p < q+qsize && q < p+size
+	}
+	t0, t1 := (q-(p+size))/d, (q+qsize-p)/d
+	if t0 > t1 {
+		t0, t1 = t1, t0
+	}
+	*enter = math.Max(*enter, t0)
+	*exit = math.Min(*exit, t1)
+	return *enter < *exit
+}
+
+// overlaps reports whether two rectangles share any area.
+func overlaps(ax, ay, aw, ah, bx, by, bw, bh float64) bool {
+	return ax < bx+bw && bx < ax+aw && ay < by+bh && by < ay+ah
+}
+
+// RectCollision reports whether rectangles a and b overlap. With eqa set,
+// rectangles that only touch along an edge collide too.
+func RectCollision(aWidth, aHeight int, aX, aY float64, bWidth, bHeight int, bX, bY float64, eqa bool) bool {
+	a := Rect{aX, aY, float64(aWidth), float64(aHeight)}
+	b := Rect{bX, bY, float64(bWidth), float64(bHeight)}
+	if eqa {
+		return a.Touches(b)
+	}
+	return a.Overlaps(b)
+}
+
+func CheckCollision(A, B Entity, eqa bool) bool {
+	if eqa {
+		return Bounds(A).Touches(Bounds(B))
+	}
+	return Bounds(A).Overlaps(Bounds(B))
+}
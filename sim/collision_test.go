@@ -0,0 +1,62 @@
+package sim
+
+import "testing"
+
+func TestRectOverlapsTouches(t *testing.T) {
+	tile := Rect{16, 16, 16, 16}
+	tests := []struct {
+		name     string
+		r        Rect
+		overlaps bool
+		touches  bool
+	}{
+		{"same", tile, true, true},
+		{"offset same size", Rect{24, 8, 16, 16}, true, true},
+		{"thin inside", Rect{20, 18, 6, 8}, true, true},
+		{"thin across", Rect{22, 0, 2, 48}, true, true},
+		{"shared edge", Rect{32, 16, 16, 16}, false, true},
+		{"shared corner", Rect{0, 0, 16, 16}, false, true},
+		{"apart", Rect{33, 16, 16, 16}, false, false},
+	}
+	for _, tt := range tests {
+		for _, order := range [2][2]Rect{{tt.r, tile}, {tile, tt.r}} {
+			a, b := order[0], order[1]
+			if got := a.Overlaps(b); got != tt.overlaps {
+				t.Errorf("%s: %v.Overlaps(%v) = %v, want %v", tt.name, a, b, got, tt.overlaps)
+			}
+			if got := a.Touches(b); got != tt.touches {
+				t.Errorf("%s: %v.Touches(%v) = %v, want %v", tt.name, a, b, got, tt.touches)
+			}
+		}
+	}
+}
+
+func TestRectSweep(t *testing.T) {
+	tests := []struct {
+		name   string
+		r      Rect
+		dx, dy float64
+		o      Rect
+		at     float64
+		hit    bool
+	}{
+		{"bullet into tile", Rect{4, 40, 6, 8}, 0, -40, Rect{0, 8, 16, 16}, 0.4, true},
+		{"bullet through tile", Rect{4, 100, 6, 8}, 0, -100, Rect{0, 40, 16, 16}, 0.44, true},
+		{"bullet stops short", Rect{4, 100, 6, 8}, 0, -40, Rect{0, 40, 16, 16}, 0, false},
+		{"offset same size", Rect{0, 0, 16, 16}, 32, 0, Rect{24, 8, 16, 16}, 0.25, true},
+		{"diagonal", Rect{0, 0, 16, 16}, 32, 32, Rect{24, 24, 16, 16}, 0.25, true},
+		{"overlapping at start", Rect{0, 0, 16, 16}, 10, 0, Rect{8, 8, 16, 16}, 0, true},
+		{"moving away", Rect{0, 0, 16, 16}, -10, 0, Rect{20, 0, 16, 16}, 0, false},
+		{"standing still inside", Rect{0, 0, 16, 16}, 0, 0, Rect{8, 8, 16, 16}, 0, true},
+		{"standing still apart", Rect{0, 0, 16, 16}, 0, 0, Rect{16, 0, 16, 16}, 0, false},
+		{"sliding along an edge", Rect{0, 0, 16, 16}, 32, 0, Rect{8, 16, 16, 16}, 0, false},
+		{"beside the path", Rect{0, 0, 16, 16}, 0, 40, Rect{16, 30, 16, 16}, 0, false},
+		{"ending against it", Rect{0, 0, 16, 16}, 8, 0, Rect{24, 0, 16, 16}, 0, false},
+	}
+	for _, tt := range tests {
+		at, hit := tt.r.Sweep(tt.dx, tt.dy, tt.o)
+		if hit != tt.hit || hit && at != tt.at {
+			t.Errorf("%s: %v.Sweep(%v, %v, %v) = %v, %v, want %v, %v", tt.name, tt.r, tt.dx, tt.dy, tt.o, at, hit, tt.at, tt.hit)
+		}
+	}
+}
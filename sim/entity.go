@@ -0,0 +1,483 @@
+package sim
+
+import (
+	"fmt"
+	"math"
+)
+
+type Entity interface {
+	GetInfo() (Width, Height int, X, Y float64)
+}
+
+const (
+	ScreenWidth  = 416
+	ScreenHeight = 416
+	TileSize     = 16
+)
+
+// Sizes are in screen pixels, i.e. the sprite size scaled by 2.
+const (
+	playerWidth  = 26
+	playerHeight = 26
+	enemyWidth   = 26
+	enemyHeight  = 30
+	bulletWidth  = 6
+	bulletHeight = 8
+	otherWidth   = TileSize
+	otherHeight  = TileSize
+	castleWidth  = 2 * TileSize
+	castleHeight = 2 * TileSize
+)
+
+const (
+	playerLives = 3
+	// shieldTicks is how long a player is shielded after it (re)spawns.
+	shieldTicks = 3 * TickRate
+)
+
+// castleExplodeTicks is how long the castle burns before it is destroyed.
+const castleExplodeTicks = 3 * TickRate / 4
+
+type Bullet struct {
+	Width  int
+	Height int
+	X      float64
+	Y      float64
+	F      int
+	Speed  float64 // pixels per second
+	Owner  *Tank
+	Power  int // stars of the owner when fired
+	gone   bool
+	prevX  float64
+	prevY  float64
+}
+
+type EnemyType int
+
+// The four enemy classes.
+const (
+	BasicTank EnemyType = iota
+	FastTank
+	PowerTank
+	ArmorTank
+	EnemyTypes int = iota
+)
+
+// EnemyStats is what sets one enemy class apart from the others. Speeds are
+// in pixels per second and the cooldown is the least number of ticks between shots.
+// An enemy ready to fire with no target in sight takes a shot at random with a
+// one in FireChance chance per tick.
+type EnemyStats struct {
+	Speed        float64
+	BulletSpeed  float64
+	FireCooldown int
+	FireChance   int
+	HitPoints    int
+	Points       int
+}
+
+var enemyStats = [EnemyTypes]EnemyStats{
+	BasicTank: {Speed: 30, BulletSpeed: 120, FireCooldown: 3 * TickRate / 2, FireChance: 32, HitPoints: 1, Points: 100},
+	FastTank:  {Speed: 90, BulletSpeed: 180, FireCooldown: 3 * TickRate / 2, FireChance: 24, HitPoints: 1, Points: 200},
+	PowerTank: {Speed: 60, BulletSpeed: 240, FireCooldown: TickRate, FireChance: 12, HitPoints: 1, Points: 300},
+	ArmorTank: {Speed: 60, BulletSpeed: 180, FireCooldown: TickRate, FireChance: 20, HitPoints: 4, Points: 400},
+}
+
+var enemyNames = [EnemyTypes]string{"basic", "fast", "power", "armor"}
+
+func (t EnemyType) String() string {
+	if t < 0 || int(t) >= EnemyTypes {
+		return fmt.Sprintf("EnemyType(%d)", int(t))
+	}
+	return enemyNames[t]
+}
+
+// ParseEnemyType is the inverse of EnemyType.String.
+func ParseEnemyType(s string) (EnemyType, error) {
+	for i, name := range enemyNames {
+		if s == name {
+			return EnemyType(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown tank type %q", s)
+}
+
+func (t EnemyType) Stats() EnemyStats {
+	return enemyStats[t]
+}
+
+// Points is what destroying an enemy of type t is worth.
+func (t EnemyType) Points() int {
+	return enemyStats[t].Points
+}
+
+type Tank struct {
+	Enemy    bool
+	Type     EnemyType
+	Width    int
+	Height   int
+	X        float64
+	Y        float64
+	Face     int
+	Speed    float64 // pixels per second
+	Failed   bool
+	Lives    int // players only, counting the tank on the field
+	Shield   int // ticks left of the spawn shield
+	Score    int // players only, running total over all levels
+	Kills    [EnemyTypes]int
+	Stars    int  // players only, fire power upgrades from stars
+	Bonus    bool // enemies only, drops a power-up when hit
+	HP       int  // enemies only, hits left before it is destroyed
+	Cooldown int  // ticks left before the tank may fire again
+	Slide    int  // players only, ticks left sliding on ice
+	Team     Team
+	Frozen   int // ticks left unable to move or shoot after friendly fire
+	prevX    float64
+	prevY    float64
+}
+
+// Tile types of an Other.
+const (
+	Brick = iota
+	Steel
+	Water
+	Grass
+	Ice
+)
+
+// Every tile is split into CellsPerSide x CellsPerSide cells so that bricks
+// can be chipped away a bit at a time.
+const (
+	CellsPerSide = 4
+	CellSize     = TileSize / CellsPerSide
+	fullMask     = 1<<(CellsPerSide*CellsPerSide) - 1
+)
+
+type Other struct {
+	Width  int
+	Height int
+	X      float64
+	Y      float64
+	T      int
+	Mask   uint16 // cells still standing, bit row*CellsPerSide+column
+}
+
+type Castle struct {
+	Width  int
+	Height int
+	X      float64
+	Y      float64
+	Mode   int //standing: 0, exploding: 1, destroyed: 2
+	Timer  int // ticks left until an exploding castle is destroyed
+}
+
+func (o *Other) GetInfo() (Width, Height int, X, Y float64) {
+	return o.Width, o.Height, o.X, o.Y
+}
+
+// Whole reports whether no part of the tile has been shot away.
+func (o *Other) Whole() bool {
+	return o.Mask == fullMask
+}
+
+// Cell reports whether the cell at row r and column c is still standing.
+func (o *Other) Cell(r, c int) bool {
+	return o.Mask&(1<<(r*CellsPerSide+c)) != 0
+}
+
+func (o *Other) cellRect(r, c int) (x, y float64) {
+	return o.X + float64(c*CellSize), o.Y + float64(r*CellSize)
+}
+
+// Overlaps reports whether the rectangle shares any area with the cells of
+// the tile still standing.
+func (o *Other) Overlaps(x, y, w, h float64) bool {
+	if !overlaps(o.X, o.Y, float64(o.Width), float64(o.Height), x, y, w, h) {
+		return false
+	}
+	if o.Whole() {
+		return true
+	}
+	for r := 0; r < CellsPerSide; r++ {
+		for c := 0; c < CellsPerSide; c++ {
+			cx, cy := o.cellRect(r, c)
+			if o.Cell(r, c) && overlaps(cx, cy, CellSize, CellSize, x, y, w, h) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Sweep reports whether r moving by dx, dy runs into a standing cell of the
+// tile, and how far along the move it first does.
+func (o *Other) Sweep(r Rect, dx, dy float64) (float64, bool) {
+	if _, hit := r.Sweep(dx, dy, Bounds(o)); !hit {
+		return 0, false
+	}
+	first, hit := 1.0, false
+	for row := 0; row < CellsPerSide; row++ {
+		for c := 0; c < CellsPerSide; c++ {
+			if !o.Cell(row, c) {
+				continue
+			}
+			x, y := o.cellRect(row, c)
+			if t, ok := r.Sweep(dx, dy, Rect{x, y, CellSize, CellSize}); ok && t <= first {
+				first, hit = t, true
+			}
+		}
+	}
+	return first, hit
+}
+
+// clear knocks out the cells overlapping the rectangle and reports whether
+// anything of the tile is left.
+func (o *Other) clear(x, y, w, h float64) bool {
+	for r := 0; r < CellsPerSide; r++ {
+		for c := 0; c < CellsPerSide; c++ {
+			cx, cy := o.cellRect(r, c)
+			if overlaps(cx, cy, CellSize, CellSize, x, y, w, h) {
+				o.Mask &^= 1 << (r*CellsPerSide + c)
+			}
+		}
+	}
+	return o.Mask != 0
+}
+
+// BlocksTank reports whether tanks can't drive through the tile.
+func (o *Other) BlocksTank() bool {
+	return o.T == Brick || o.T == Steel || o.T == Water
+}
+
+// StopsBullet reports whether bullets can't fly through the tile.
+func (o *Other) StopsBullet() bool {
+	return o.T == Brick || o.T == Steel
+}
+
+// Breaks reports whether a bullet of the given power destroys the tile.
+// Steel only gives in to fully upgraded shots.
+func (o *Other) Breaks(power int) bool {
+	return o.T == Brick || o.T == Steel && power >= maxStars
+}
+
+func (c *Castle) GetInfo() (Width, Height int, X, Y float64) {
+	return c.Width, c.Height, c.X, c.Y
+}
+
+// Hit starts the explosion of a standing castle.
+func (c *Castle) Hit() {
+	if c.Mode == 0 {
+		c.Mode = 1
+		c.Timer = castleExplodeTicks
+	}
+}
+
+// Update runs the explosion and reports the castle destroyed once it is over.
+func (c *Castle) Update() {
+	if c.Mode == 1 {
+		c.Timer--
+		if c.Timer == 0 {
+			c.Mode = 2
+		}
+	}
+}
+
+// Explosion returns how far the explosion has got, from 0 when the castle is
+// hit to 1 when it is destroyed.
+func (c *Castle) Explosion() float64 {
+	if c.Mode != 1 {
+		return 0
+	}
+	return float64(castleExplodeTicks-c.Timer) / castleExplodeTicks
+}
+
+func (t *Tank) Turn(i int) {
+	t.Face = i
+}
+
+func (t *Tank) GetInfo() (Width, Height int, X, Y float64) {
+	return t.Width, t.Height, t.X, t.Y
+}
+
+// MaxBullets is how many bullets of its own t may have on the field at once:
+// one, or two once a player has picked up a star.
+func (t *Tank) MaxBullets() int {
+	if !t.Enemy && t.Stars >= 1 {
+		return 2
+	}
+	return 1
+}
+
+// Out reports whether a player has lost its last life.
+func (t *Tank) Out() bool {
+	return t.Failed && t.Lives == 0
+}
+
+// Hit takes a life from a player unless its shield is up, and reports
+// whether the bullet got through.
+func (t *Tank) Hit() bool {
+	if t.Shield > 0 {
+		return false
+	}
+	t.Failed = true
+	t.Lives--
+	t.Stars = 0
+	return true
+}
+
+// step is how far t moves in one tick.
+func (t *Tank) step() float64 {
+	return t.Speed / TickRate
+}
+
+// Lerp returns where to draw t alpha of the way, from 0 to 1, from where it
+// was before the last tick to where it is now.
+func (t *Tank) Lerp(alpha float64) (x, y float64) {
+	return lerp(t.prevX, t.X, alpha), lerp(t.prevY, t.Y, alpha)
+}
+
+// settle makes t's current position its previous one, so it won't be drawn
+// gliding over from wherever it was before.
+func (t *Tank) settle() {
+	t.prevX, t.prevY = t.X, t.Y
+}
+
+func (b *Bullet) Move() {
+	b.prevX, b.prevY = b.X, b.Y
+	d := b.Speed / TickRate
+	switch b.F {
+	case 0:
+		b.Y -= d
+	case 1:
+		b.X += d
+	case 2:
+		b.Y += d
+	default:
+		b.X -= d
+	}
+}
+
+// Lerp returns where to draw b alpha of the way through its last move.
+func (b *Bullet) Lerp(alpha float64) (x, y float64) {
+	return lerp(b.prevX, b.X, alpha), lerp(b.prevY, b.Y, alpha)
+}
+
+func lerp(a, b, alpha float64) float64 {
+	return a + (b-a)*alpha
+}
+
+func (b *Bullet) GetInfo() (Width, Height int, X, Y float64) {
+	return b.Width, b.Height, b.X, b.Y
+}
+
+func (t *Tank) Fire() *Bullet {
+	// The spawn offsets are worked out in sprite pixels, half the screen size.
+	w, h := float64(t.Width)/2, float64(t.Height)/2
+	dx, dy := float64(bulletWidth)/2, float64(bulletHeight)/2
+
+	var x, y float64
+	switch t.Face {
+	case 0:
+		x, y = t.X+w-dx, t.Y-2*dy
+	case 1:
+		x, y = t.X+h*2, t.Y+w-dx
+	case 2:
+		x, y = t.X+w-dx, t.Y+h*2
+	default:
+		x, y = t.X-h, t.Y+w-dx
+	}
+	speed := float64(120)
+	if t.Enemy {
+		speed = t.Type.Stats().BulletSpeed
+	} else if t.Stars > 0 {
+		speed *= 2
+	}
+	bullet := &Bullet{
+		Width:  bulletWidth,
+		Height: bulletHeight,
+		X:      x,
+		Y:      y,
+		F:      t.Face,
+		Speed:  speed,
+		Owner:  t,
+		Power:  t.Stars,
+		prevX:  x,
+		prevY:  y,
+	}
+	return bullet
+}
+
+func NewEnemy(t EnemyType, F int, x, y float64) *Tank {
+	stats := t.Stats()
+	tank := &Tank{
+		Enemy:  true,
+		Team:   TeamEnemies,
+		Type:   t,
+		Width:  enemyWidth,
+		Height: enemyHeight,
+		X:      x,
+		Y:      y,
+		Face:   F,
+		Failed: false,
+		Speed:  stats.Speed,
+		HP:     stats.HitPoints,
+		prevX:  x,
+		prevY:  y,
+	}
+	return tank
+}
+
+func NewOther(x, y float64, t int) *Other {
+	o := &Other{
+		Width:  otherWidth,
+		Height: otherHeight,
+		X:      x,
+		Y:      y,
+		T:      t,
+		Mask:   fullMask,
+	}
+	return o
+}
+
+func NewPlayer(player int) *Tank {
+	x, y := NewLevel().start(player)
+
+	tank := &Tank{
+		Enemy:  false,
+		Team:   TeamPlayers,
+		Width:  playerWidth,
+		Height: playerHeight,
+		X:      x,
+		Y:      y,
+		Face:   0,
+		Speed:  60,
+		Lives:  playerLives,
+		Shield: shieldTicks,
+		prevX:  x,
+		prevY:  y,
+	}
+	return tank
+}
+
+func NewCastle(x, y float64) *Castle {
+	castle := &Castle{
+		Width:  castleWidth,
+		Height: castleHeight,
+		X:      x,
+		Y:      y,
+		Mode:   0,
+	}
+	return castle
+}
+
+func NotSafe(t1, t2 *Tank) bool {
+	w1, h1, x_1, y_1 := t1.GetInfo()
+	x1, y1 := x_1+float64(w1)/2, y_1+float64(h1)/2
+	w2, h2, x_2, y_2 := t2.GetInfo()
+	x2, y2 := x_2+float64(w2)/2, y_2+float64(h2)/2
+	if math.Pow(x1-x2, 2)+math.Pow(y1-y2, 2) <= math.Pow(float64(w1), 2)+math.Pow(float64(h1), 2) {
+		return true
+	}
+	return false
+
+}
@@ -0,0 +1,176 @@
+package sim
+
+import "math"
+
+// bucketSize is the side of the squares tanks are hashed into. A tank is
+// smaller than a bucket, so it never sits in more than four.
+const bucketSize = 2 * TileSize
+
+// Grid indexes the tiles by their slot on the tile grid and the tanks by the
+// buckets they touch, so a collision query only looks at what is nearby
+// instead of at everything on the field.
+type Grid struct {
+	cols, rows int
+	tiles      []*Other
+	bucketCols int
+	bucketRows int
+	buckets    [][]*Tank
+	spans      map[*Tank][4]int // bucket columns and rows each tank is in
+	seen       map[*Tank]bool
+}
+
+func NewGrid(width, height int) *Grid {
+	g := &Grid{
+		cols:       width / TileSize,
+		rows:       height / TileSize,
+		bucketCols: (width + bucketSize - 1) / bucketSize,
+		bucketRows: (height + bucketSize - 1) / bucketSize,
+		spans:      make(map[*Tank][4]int),
+		seen:       make(map[*Tank]bool),
+	}
+	g.tiles = make([]*Other, g.cols*g.rows)
+	g.buckets = make([][]*Tank, g.bucketCols*g.bucketRows)
+	return g
+}
+
+// TileAt returns the tile in column col and row row, or nil.
+func (g *Grid) TileAt(col, row int) *Other {
+	if col < 0 || row < 0 || col >= g.cols || row >= g.rows {
+		return nil
+	}
+	return g.tiles[row*g.cols+col]
+}
+
+func (g *Grid) slot(o *Other) int {
+	col, row := int(o.X)/TileSize, int(o.Y)/TileSize
+	if col < 0 || row < 0 || col >= g.cols || row >= g.rows {
+		return -1
+	}
+	return row*g.cols + col
+}
+
+func (g *Grid) AddTile(o *Other) {
+	if i := g.slot(o); i >= 0 {
+		g.tiles[i] = o
+	}
+}
+
+func (g *Grid) RemoveTile(o *Other) {
+	if i := g.slot(o); i >= 0 && g.tiles[i] == o {
+		g.tiles[i] = nil
+	}
+}
+
+// span returns the first and last index along one axis of the cells of size
+// size a segment from x to x+w touches, clamped to n cells. Touching an edge
+// counts, as it does for RectCollision.
+func span(x, w float64, size, n int) (int, int) {
+	first := int(math.Floor(x / float64(size)))
+	last := int(math.Floor((x + w) / float64(size)))
+	if first < 0 {
+		first = 0
+	}
+	if last >= n {
+		last = n - 1
+	}
+	return first, last
+}
+
+// bucketSpan is span over the buckets. Whatever is past the edge of the
+// field goes in the edge buckets, so a tank poking out over it is still found
+// by a query that is all the way out.
+func (g *Grid) bucketSpan(x, y, w, h float64) (c0, c1, r0, r1 int) {
+	c0, c1 = span(x, w, bucketSize, g.bucketCols)
+	r0, r1 = span(y, h, bucketSize, g.bucketRows)
+	if c0 >= g.bucketCols {
+		c0 = g.bucketCols - 1
+	}
+	if c1 < 0 {
+		c1 = 0
+	}
+	if r0 >= g.bucketRows {
+		r0 = g.bucketRows - 1
+	}
+	if r1 < 0 {
+		r1 = 0
+	}
+	return c0, c1, r0, r1
+}
+
+// Tiles returns the tiles whose slot touches the rectangle, row by row.
+func (g *Grid) Tiles(x, y, w, h float64) []*Other {
+	c0, c1 := span(x, w, TileSize, g.cols)
+	r0, r1 := span(y, h, TileSize, g.rows)
+	var tiles []*Other
+	for r := r0; r <= r1; r++ {
+		for c := c0; c <= c1; c++ {
+			if o := g.tiles[r*g.cols+c]; o != nil {
+				tiles = append(tiles, o)
+			}
+		}
+	}
+	return tiles
+}
+
+// MoveTank puts t in the buckets of where it is now, adding it to the index
+// if it wasn't there yet.
+func (g *Grid) MoveTank(t *Tank) {
+	c0, c1, r0, r1 := g.bucketSpan(t.X, t.Y, float64(t.Width), float64(t.Height))
+	s := [4]int{c0, c1, r0, r1}
+	if old, ok := g.spans[t]; ok {
+		if old == s {
+			return
+		}
+		g.unbucket(t, old)
+	}
+	g.spans[t] = s
+	for r := r0; r <= r1; r++ {
+		for c := c0; c <= c1; c++ {
+			i := r*g.bucketCols + c
+			g.buckets[i] = append(g.buckets[i], t)
+		}
+	}
+}
+
+func (g *Grid) RemoveTank(t *Tank) {
+	if s, ok := g.spans[t]; ok {
+		g.unbucket(t, s)
+		delete(g.spans, t)
+	}
+}
+
+func (g *Grid) unbucket(t *Tank, s [4]int) {
+	for r := s[2]; r <= s[3]; r++ {
+		for c := s[0]; c <= s[1]; c++ {
+			i := r*g.bucketCols + c
+			b := g.buckets[i]
+			for j, other := range b {
+				if other == t {
+					g.buckets[i] = append(b[:j], b[j+1:]...)
+					break
+				}
+			}
+		}
+	}
+}
+
+// Tanks returns the tanks in the buckets the rectangle touches, each once.
+// They are only candidates, callers still test for the actual collision.
+func (g *Grid) Tanks(x, y, w, h float64) []*Tank {
+	c0, c1, r0, r1 := g.bucketSpan(x, y, w, h)
+	var tanks []*Tank
+	for r := r0; r <= r1; r++ {
+		for c := c0; c <= c1; c++ {
+			for _, t := range g.buckets[r*g.bucketCols+c] {
+				if !g.seen[t] {
+					g.seen[t] = true
+					tanks = append(tanks, t)
+				}
+			}
+		}
+	}
+	for _, t := range tanks {
+		delete(g.seen, t)
+	}
+	return tanks
+}
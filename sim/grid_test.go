@@ -0,0 +1,117 @@
+package sim
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestGridTiles(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	g := NewGrid(ScreenWidth, ScreenHeight)
+	var slots [GridSize][GridSize]*Other
+	for i := 0; i < 5000; i++ {
+		c, row := r.Intn(GridSize), r.Intn(GridSize)
+		if o := slots[c][row]; o != nil && r.Intn(2) == 0 {
+			g.RemoveTile(o)
+			slots[c][row] = nil
+		} else {
+			if o != nil {
+				g.RemoveTile(o)
+			}
+			o = NewOther(float64(c*TileSize), float64(row*TileSize), Brick)
+			g.AddTile(o)
+			slots[c][row] = o
+		}
+		for c := 0; c < GridSize; c++ {
+			for row := 0; row < GridSize; row++ {
+				if got := g.TileAt(c, row); got != slots[c][row] {
+					t.Fatalf("step %d: TileAt(%d, %d) = %p, want %p", i, c, row, got, slots[c][row])
+				}
+			}
+		}
+
+		q := Rect{r.Float64()*ScreenWidth - 20, r.Float64()*ScreenHeight - 20, r.Float64() * 60, r.Float64() * 60}
+		got := make(map[*Other]bool)
+		for _, o := range g.Tiles(q.X, q.Y, q.W, q.H) {
+			if got[o] {
+				t.Fatalf("step %d: Tiles(%v) has %v twice", i, q, o)
+			}
+			got[o] = true
+		}
+		want := 0
+		for c := range slots {
+			for _, o := range slots[c] {
+				if o == nil {
+					continue
+				}
+				if Bounds(o).Touches(q) {
+					want++
+					if !got[o] {
+						t.Fatalf("step %d: Tiles(%v) misses the tile at %v,%v", i, q, o.X, o.Y)
+					}
+				}
+			}
+		}
+		if len(got) != want {
+			t.Fatalf("step %d: Tiles(%v) = %d tiles, want %d", i, q, len(got), want)
+		}
+	}
+}
+
+func TestGridTanks(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	g := NewGrid(ScreenWidth, ScreenHeight)
+	all := make([]*Tank, 12)
+	for i := range all {
+		if i%2 == 0 {
+			all[i] = NewPlayer(0)
+		} else {
+			all[i] = NewEnemy(BasicTank, 2, 0, 0)
+		}
+	}
+	live := make(map[*Tank]bool)
+	field := Rect{0, 0, ScreenWidth, ScreenHeight}
+	for i := 0; i < 5000; i++ {
+		tank := all[r.Intn(len(all))]
+		switch {
+		case live[tank] && r.Intn(4) == 0:
+			g.RemoveTank(tank)
+			delete(live, tank)
+		case live[tank] && r.Intn(2) == 0:
+			// A short step, which mostly stays in the same buckets.
+			tank.X += r.Float64()*8 - 4
+			tank.Y += r.Float64()*8 - 4
+			g.MoveTank(tank)
+		default:
+			// A jump anywhere, so tanks straddle bucket edges and stick
+			// out over the edges of the field, or leave it altogether.
+			tank.X = r.Float64()*(ScreenWidth+80) - 40
+			tank.Y = r.Float64()*(ScreenHeight+80) - 40
+			g.MoveTank(tank)
+			live[tank] = true
+		}
+
+		q := Rect{r.Float64()*ScreenWidth - 20, r.Float64()*ScreenHeight - 20, r.Float64() * 60, r.Float64() * 60}
+		got := make(map[*Tank]bool)
+		for _, tank := range g.Tanks(q.X, q.Y, q.W, q.H) {
+			if got[tank] {
+				t.Fatalf("step %d: Tanks(%v) has a tank twice", i, q)
+			}
+			if !live[tank] {
+				t.Fatalf("step %d: Tanks(%v) has a removed tank", i, q)
+			}
+			got[tank] = true
+		}
+		for tank := range live {
+			if Bounds(tank).Touches(q) && !got[tank] {
+				t.Fatalf("step %d: Tanks(%v) misses the tank at %v", i, q, Bounds(tank))
+			}
+			// Anything on the field further than a bucket away shouldn't
+			// be a candidate. Tanks off it are all in the edge buckets.
+			near := Rect{q.X - bucketSize, q.Y - bucketSize, q.W + 2*bucketSize, q.H + 2*bucketSize}
+			if got[tank] && Bounds(tank).Touches(field) && !Bounds(tank).Touches(near) {
+				t.Fatalf("step %d: Tanks(%v) has the far off tank at %v", i, q, Bounds(tank))
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package sim
+
+// Input is what a player asks for on one tick. When several directions are
+// held, the first one of Up, Right, Down, Left wins.
+type Input struct {
+	Up    bool
+	Right bool
+	Down  bool
+	Left  bool
+	Fire  bool
+}
+
+// Dir returns the face the input points to, or -1 when no direction is held.
+func (in Input) Dir() int {
+	switch {
+	case in.Up:
+		return 0
+	case in.Right:
+		return 1
+	case in.Down:
+		return 2
+	case in.Left:
+		return 3
+	}
+	return -1
+}
+
+// Merge returns an input holding everything held in either in or other.
+func (in Input) Merge(other Input) Input {
+	return Input{
+		Up:    in.Up || other.Up,
+		Right: in.Right || other.Right,
+		Down:  in.Down || other.Down,
+		Left:  in.Left || other.Left,
+		Fire:  in.Fire || other.Fire,
+	}
+}
+
+// InputSource drives one player. Input is called exactly once per tick, so
+// sources that detect key presses or walk a list can keep their own state.
+type InputSource interface {
+	Input() Input
+}
+
+// InputFunc adapts a plain function, such as a bot, to an InputSource.
+type InputFunc func() Input
+
+func (f InputFunc) Input() Input {
+	return f()
+}
+
+// NoInput never presses anything. It stands in for a player nobody controls.
+type NoInput struct{}
+
+func (NoInput) Input() Input {
+	return Input{}
+}
+
+// Script plays a fixed list of inputs, one per tick. Past the end it starts
+// over when Loop is set and stays idle otherwise.
+type Script struct {
+	Inputs []Input
+	Loop   bool
+	next   int
+}
+
+func (s *Script) Input() Input {
+	if s.next == len(s.Inputs) {
+		if !s.Loop || len(s.Inputs) == 0 {
+			return Input{}
+		}
+		s.next = 0
+	}
+	in := s.Inputs[s.next]
+	s.next++
+	return in
+}
+
+// Done reports whether a non looping script has run out of inputs.
+func (s *Script) Done() bool {
+	return !s.Loop && s.next == len(s.Inputs)
+}
+
+// ReplayInput plays back the recorded inputs of one player of a replay.
+type ReplayInput struct {
+	Replay *Replay
+	Player int
+	next   int
+}
+
+func (r *ReplayInput) Input() Input {
+	if r.next == r.Replay.Len() {
+		return Input{}
+	}
+	in := r.Replay.Tick(r.next)[r.Player]
+	r.next++
+	return in
+}
+
+// Done reports whether every recorded tick has been played.
+func (r *ReplayInput) Done() bool {
+	return r.next == r.Replay.Len()
+}
+
+// Poll reads one tick from each source.
+func Poll(sources [2]InputSource) [2]Input {
+	return [2]Input{sources[0].Input(), sources[1].Input()}
+}
@@ -0,0 +1,505 @@
+package sim
+
+import (
+	"fmt"
+	"io/fs"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	levels "github.com/ShaolingPu/battleCity/resources/levels/tank"
+)
+
+// levelVersion is the level file format Encode writes. A version 2 file opens
+// with a "battlecity level 2" line and "key: value" header lines, then a blank
+// line and the grid. A file without that first line is a plain grid from
+// before, and gets what NewLevel sets up: 20 basic tanks, at most four on the
+// field at once, from the three usual spawns.
+const (
+	levelMagic   = "battlecity level"
+	levelVersion = 2
+)
+
+// Level is a level file: the grid, what enemies it sends and where everything
+// starts. Positions are the top left corners, in screen pixels.
+type Level struct {
+	Name       string
+	Author     string
+	Enemies    []EnemyType // in the order they come out, unless Shuffle is set
+	Shuffle    bool
+	Spawns     [][2]float64 // where enemies come out
+	Players    [2][2]float64
+	Castle     [2]float64
+	MaxEnemies int // enemies on the field at once
+	Grid       []string
+}
+
+// NewLevel returns an empty level laid out like the arcade ones, sending 20
+// basic tanks.
+func NewLevel() *Level {
+	l := &Level{
+		Shuffle:    true,
+		Spawns:     [][2]float64{{3, 3}, {192, 3}, {381, 3}},
+		Players:    [2][2]float64{{144, 384}, {240 + 3, 384}},
+		Castle:     [2]float64{192, 384},
+		MaxEnemies: 4,
+	}
+	for i := 0; i < 20; i++ {
+		l.Enemies = append(l.Enemies, BasicTank)
+	}
+	return l
+}
+
+// start is where player starts.
+func (l *Level) start(player int) (x, y float64) {
+	return l.Players[player][0], l.Players[player][1]
+}
+
+// SpawnAt is the spawn point for enemies coming out on the two by two tiles
+// with column c and row r at their top left.
+func SpawnAt(c, r int) [2]float64 {
+	return [2]float64{float64(c*TileSize + (2*TileSize-enemyWidth)/2), float64(r*TileSize + (2*TileSize-enemyHeight)/2)}
+}
+
+// StartAt is the player start on the two by two tiles with column c and row r
+// at their top left.
+func StartAt(c, r int) [2]float64 {
+	return [2]float64{float64(c*TileSize + (2*TileSize-playerWidth)/2), float64(r*TileSize + (2*TileSize-playerHeight)/2)}
+}
+
+// SpawnTile returns the column and row of the top left of the tiles an enemy
+// coming out at spawn point p stands on.
+func SpawnTile(p [2]float64) (c, r int) {
+	b := block(p, enemyWidth, enemyHeight)
+	return int(b.X) / TileSize, int(b.Y) / TileSize
+}
+
+// StartTile returns the column and row of the top left of the tiles a player
+// starting at p stands on.
+func StartTile(p [2]float64) (c, r int) {
+	b := block(p, playerWidth, playerHeight)
+	return int(b.X) / TileSize, int(b.Y) / TileSize
+}
+
+// GridSize is the number of tiles along each side of a level.
+const GridSize = ScreenWidth / TileSize
+
+// tileChars are the characters a grid is made of.
+const tileChars = ".#@%~-"
+
+// LevelError is one problem with a level file, at a line and column counting
+// from 1. Col is 0 when the problem is with the line as a whole.
+type LevelError struct {
+	Line int
+	Col  int
+	Msg  string
+}
+
+func (e *LevelError) Error() string {
+	if e.Col == 0 {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+	}
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Col, e.Msg)
+}
+
+// LevelErrors is every problem found in one level file.
+type LevelErrors []*LevelError
+
+func (e LevelErrors) Error() string {
+	s := make([]string, len(e))
+	for i, err := range e {
+		s[i] = err.Error()
+	}
+	return strings.Join(s, "\n")
+}
+
+// EmbeddedLevels returns the levels built into the game, one file per level.
+func EmbeddedLevels() fs.FS {
+	fsys, err := fs.Sub(levels.Levels, "levels")
+	if err != nil {
+		// Only fails for a malformed path, which "levels" is not.
+		panic(err)
+	}
+	return fsys
+}
+
+// LoadLevel reads embedded level i.
+func LoadLevel(i int) (*Level, error) {
+	return readLevel(EmbeddedLevels(), strconv.Itoa(i))
+}
+
+func readLevel(fsys fs.FS, name string) (*Level, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	l, err := DecodeLevel(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return l, nil
+}
+
+// LoadLevels reads the embedded levels and then those in each of extra, in
+// play order. A file in extra replaces the level of the same name read before
+// it, so "3" replaces the third level, and any other file adds a level.
+func LoadLevels(extra ...fs.FS) ([]*Level, error) {
+	byName := map[string]*Level{}
+	for _, fsys := range append([]fs.FS{EmbeddedLevels()}, extra...) {
+		names, err := LevelFiles(fsys)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			l, err := readLevel(fsys, name)
+			if err != nil {
+				return nil, err
+			}
+			byName[name] = l
+		}
+	}
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sortLevelNames(names)
+	ls := make([]*Level, len(names))
+	for i, name := range names {
+		ls[i] = byName[name]
+	}
+	return ls, nil
+}
+
+// LevelFiles lists the level files at the top of fsys in play order.
+func LevelFiles(fsys fs.FS) ([]string, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if e.Type().IsRegular() && !strings.HasPrefix(e.Name(), ".") {
+			names = append(names, e.Name())
+		}
+	}
+	sortLevelNames(names)
+	return names, nil
+}
+
+// sortLevelNames puts level files in play order: those named by a number
+// first, by that number, then the others by name.
+func sortLevelNames(names []string) {
+	sort.Slice(names, func(i, j int) bool {
+		a, errA := strconv.Atoi(names[i])
+		b, errB := strconv.Atoi(names[j])
+		switch {
+		case errA == nil && errB == nil:
+			return a < b
+		case errA == nil || errB == nil:
+			return errA == nil
+		}
+		return names[i] < names[j]
+	})
+}
+
+// GetLevel returns the grid of embedded level i.
+func GetLevel(i int) ([]string, error) {
+	l, err := LoadLevel(i)
+	if err != nil {
+		return nil, err
+	}
+	return l.Grid, nil
+}
+
+// decoder collects the problems found while decoding a level.
+type decoder struct {
+	errs LevelErrors
+}
+
+func (d *decoder) errorf(line, col int, format string, args ...any) {
+	d.errs = append(d.errs, &LevelError{Line: line, Col: col, Msg: fmt.Sprintf(format, args...)})
+}
+
+// DecodeLevel reads a level file of either version. It checks that the grid
+// is GridSize tiles square and made of known tiles, that the header has
+// everything a level needs, and that everything starts on the field. If
+// anything is wrong, the error is a LevelErrors listing all of it.
+func DecodeLevel(data []byte) (*Level, error) {
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	l := NewLevel()
+	d := &decoder{}
+	first := 0
+	if strings.HasPrefix(lines[0], levelMagic) {
+		first = d.header(l, lines)
+	}
+	d.grid(l, lines, first)
+	if len(d.errs) > 0 {
+		return nil, d.errs
+	}
+	return l, nil
+}
+
+// header reads the header into l and returns the index of the first grid line.
+func (d *decoder) header(l *Level, lines []string) int {
+	version, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(lines[0], levelMagic)))
+	if err != nil || version != levelVersion {
+		d.errorf(1, len(levelMagic)+2, "unsupported level format %q, want %d", strings.TrimPrefix(lines[0], levelMagic+" "), levelVersion)
+	}
+	seen := map[string]bool{}
+	i := 1
+	for ; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+		key, value, ok := strings.Cut(lines[i], ":")
+		if !ok {
+			d.errorf(i+1, 0, "want key: value, got %q", lines[i])
+			continue
+		}
+		key = strings.TrimSpace(key)
+		if seen[key] {
+			d.errorf(i+1, 1, "%s given twice", key)
+		}
+		seen[key] = true
+		col := len(lines[i]) - len(strings.TrimLeft(value, " \t")) + 1
+		d.set(l, key, strings.TrimSpace(value), i+1, col)
+	}
+	for _, key := range []string{"enemies", "spawns", "players", "castle"} {
+		if !seen[key] {
+			d.errorf(i+1, 0, "header has no %s", key)
+		}
+	}
+	return i + 1
+}
+
+// set reads the value of one header key. The value starts at col of line.
+func (d *decoder) set(l *Level, key, value string, line, col int) {
+	switch key {
+	case "name":
+		l.Name = value
+	case "author":
+		l.Author = value
+	case "enemies":
+		l.Enemies = d.roster(value, line, col)
+	case "order":
+		switch value {
+		case "random":
+			l.Shuffle = true
+		case "fixed":
+			l.Shuffle = false
+		default:
+			d.errorf(line, col, "order %q is neither random nor fixed", value)
+		}
+	case "spawns":
+		l.Spawns = d.points(value, line, col, enemyWidth, enemyHeight)
+		if len(l.Spawns) == 0 {
+			d.errorf(line, col, "no spawn points")
+		}
+	case "players":
+		if ps := d.points(value, line, col, playerWidth, playerHeight); len(ps) == 2 {
+			l.Players = [2][2]float64{ps[0], ps[1]}
+		} else {
+			d.errorf(line, col, "%d player starts, want 2", len(ps))
+		}
+	case "castle":
+		ps := d.points(value, line, col, castleWidth, castleHeight)
+		if len(ps) != 1 {
+			d.errorf(line, col, "%d castle positions, want 1", len(ps))
+			break
+		}
+		if math.Mod(ps[0][0], TileSize) != 0 || math.Mod(ps[0][1], TileSize) != 0 {
+			d.errorf(line, col, "castle at %s is not on the tile grid", formatPoints(ps[0]))
+		}
+		l.Castle = ps[0]
+	case "max-enemies":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			d.errorf(line, col, "max-enemies %q is not a number of 1 or more", value)
+		}
+		l.MaxEnemies = n
+	default:
+		d.errorf(line, 1, "unknown key %q", key)
+	}
+}
+
+// maxRoster is the most enemies a level may send.
+const maxRoster = 500
+
+// roster reads a list like "18 basic, 2 fast", which sends 18 basic tanks
+// and then 2 fast ones.
+func (d *decoder) roster(s string, line, col int) []EnemyType {
+	var roster []EnemyType
+	for _, f := range split(s, ",") {
+		var n int
+		var name string
+		if _, err := fmt.Sscan(f.text, &n, &name); err != nil || n < 0 {
+			d.errorf(line, col+f.off, "want a count and a tank type, got %q", f.text)
+			continue
+		}
+		if n > maxRoster-len(roster) {
+			d.errorf(line, col+f.off, "more than %d enemies", maxRoster)
+			return roster
+		}
+		t, err := ParseEnemyType(name)
+		if err != nil {
+			d.errorf(line, col+f.off, "%v", err)
+			continue
+		}
+		for i := 0; i < n; i++ {
+			roster = append(roster, t)
+		}
+	}
+	if len(roster) == 0 {
+		d.errorf(line, col, "no enemies")
+	}
+	return roster
+}
+
+// points reads a space separated list of x,y pairs, each the corner of a w by
+// h box that has to be on the field.
+func (d *decoder) points(s string, line, col, w, h int) [][2]float64 {
+	var ps [][2]float64
+	for _, f := range split(s, " ") {
+		x, y, ok := strings.Cut(f.text, ",")
+		px, err1 := strconv.ParseFloat(x, 64)
+		py, err2 := strconv.ParseFloat(y, 64)
+		if !ok || err1 != nil || err2 != nil || !finite(px) || !finite(py) {
+			d.errorf(line, col+f.off, "want x,y, got %q", f.text)
+			continue
+		}
+		if px < 0 || py < 0 || px+float64(w) > ScreenWidth || py+float64(h) > ScreenHeight {
+			d.errorf(line, col+f.off, "%s is off the field", f.text)
+		}
+		ps = append(ps, [2]float64{px, py})
+	}
+	return ps
+}
+
+func finite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+// field is a piece of a header value and its offset in it.
+type field struct {
+	text string
+	off  int
+}
+
+// split cuts s at sep and trims the pieces, dropping empty ones.
+func split(s, sep string) []field {
+	var fs []field
+	off := 0
+	for _, part := range strings.Split(s, sep) {
+		text := strings.TrimSpace(part)
+		if text != "" {
+			fs = append(fs, field{text, off + strings.Index(part, text)})
+		}
+		off += len(part) + len(sep)
+	}
+	return fs
+}
+
+// grid reads the grid starting at lines[first]. A file may end in one
+// newline, but has no other blank lines after the grid.
+func (d *decoder) grid(l *Level, lines []string, first int) {
+	var rows []string
+	if first < len(lines) {
+		rows = lines[first:]
+	}
+	if n := len(rows); n > 0 && rows[n-1] == "" {
+		rows = rows[:n-1]
+	}
+	if len(rows) == 0 {
+		d.errorf(len(lines), 0, "no grid")
+		return
+	}
+	for i, row := range rows {
+		line := first + i + 1
+		if i == GridSize {
+			if strings.TrimSpace(row) == "" {
+				d.errorf(line, 0, "blank line after the grid")
+			} else {
+				d.errorf(line, 0, "grid has more than %d rows", GridSize)
+			}
+			break
+		}
+		width := 0
+		for _, ch := range row {
+			width++
+			if !strings.ContainsRune(tileChars, ch) {
+				d.errorf(line, width, "unknown tile %q", ch)
+			}
+		}
+		if width != GridSize {
+			d.errorf(line, 0, "row is %d tiles wide, want %d", width, GridSize)
+		}
+	}
+	if len(rows) < GridSize {
+		d.errorf(first+len(rows), 0, "grid has %d rows, want %d", len(rows), GridSize)
+	}
+	l.Grid = rows
+}
+
+// Encode writes l in the current level format.
+func (l *Level) Encode() []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %d\n", levelMagic, levelVersion)
+	fmt.Fprintf(&b, "name: %s\n", l.Name)
+	fmt.Fprintf(&b, "author: %s\n", l.Author)
+	var groups []string
+	for i := 0; i < len(l.Enemies); {
+		j := i
+		for j < len(l.Enemies) && l.Enemies[j] == l.Enemies[i] {
+			j++
+		}
+		groups = append(groups, fmt.Sprintf("%d %s", j-i, l.Enemies[i]))
+		i = j
+	}
+	fmt.Fprintf(&b, "enemies: %s\n", strings.Join(groups, ", "))
+	order := "fixed"
+	if l.Shuffle {
+		order = "random"
+	}
+	fmt.Fprintf(&b, "order: %s\n", order)
+	fmt.Fprintf(&b, "spawns: %s\n", formatPoints(l.Spawns...))
+	fmt.Fprintf(&b, "players: %s\n", formatPoints(l.Players[:]...))
+	fmt.Fprintf(&b, "castle: %s\n", formatPoints(l.Castle))
+	fmt.Fprintf(&b, "max-enemies: %d\n", l.MaxEnemies)
+	b.WriteString("\n")
+	for _, row := range l.Grid {
+		b.WriteString(row)
+		b.WriteString("\n")
+	}
+	return []byte(b.String())
+}
+
+func formatPoints(ps ...[2]float64) string {
+	s := make([]string, len(ps))
+	for i, p := range ps {
+		s[i] = strconv.FormatFloat(p[0], 'g', -1, 64) + "," + strconv.FormatFloat(p[1], 'g', -1, 64)
+	}
+	return strings.Join(s, " ")
+}
+
+func (w *World) ParseLevel() {
+	for i, s := range w.MapLevel {
+		for j := 0; j < len(s); j++ {
+			ch := s[j]
+			x, y := float64(j*TileSize), float64(i*TileSize)
+			var o *Other
+			switch ch {
+			case '#': //brick
+				o = NewOther(x, y, Brick)
+			case '@': //steel
+				o = NewOther(x, y, Steel)
+			case '%': //water
+				o = NewOther(x, y, Water)
+			case '~': //grass
+				o = NewOther(x, y, Grass)
+			case '-': //ice
+				o = NewOther(x, y, Ice)
+			}
+			if o != nil {
+				w.addOther(o)
+			}
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package sim
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// testLevel is a valid level file, as lines. The header keys are on lines 2
+// to 9 and the grid starts on line 11.
+func testLevel() []string {
+	lines := []string{
+		"battlecity level 2",
+		"name: Test",
+		"author: Tester",
+		"enemies: 18 basic, 2 fast",
+		"order: fixed",
+		"spawns: 3,3 192,3 381,3",
+		"players: 144,384 243,384",
+		"castle: 192,384",
+		"max-enemies: 4",
+		"",
+	}
+	for i := 0; i < GridSize; i++ {
+		lines = append(lines, strings.Repeat(".", GridSize))
+	}
+	return lines
+}
+
+func TestDecodeLevel(t *testing.T) {
+	type pos struct{ Line, Col int }
+	tests := []struct {
+		name string
+		edit func(lines []string) []string
+		raw  bool // decode data instead of the edited test level
+		data string
+		want []pos
+	}{
+		{name: "valid"},
+		{name: "crlf", raw: true, data: strings.Join(testLevel(), "\r\n") + "\r\n"},
+		{name: "plain grid", edit: func(l []string) []string { return l[10:] }},
+		{name: "empty", raw: true, want: []pos{{1, 0}}},
+		{name: "header only", edit: func(l []string) []string { return l[:10] }, want: []pos{{11, 0}}},
+		{name: "blank line after grid", raw: true, data: strings.Join(testLevel(), "\n") + "\n\n", want: []pos{{37, 0}}},
+		{name: "extra row", edit: func(l []string) []string { return append(l, l[10]) }, want: []pos{{37, 0}}},
+		{name: "missing row", edit: func(l []string) []string { return l[:35] }, want: []pos{{35, 0}}},
+		{name: "short row", edit: func(l []string) []string { l[13] = l[13][1:]; return l }, want: []pos{{14, 0}}},
+		{name: "unknown tile", edit: func(l []string) []string { l[11] = ".....x" + l[11][6:]; return l }, want: []pos{{12, 6}}},
+		{name: "unknown tile in plain grid", edit: func(l []string) []string { l[12] = "?" + l[12][1:]; return l[10:] }, want: []pos{{3, 1}}},
+		{name: "bad version", edit: func(l []string) []string { l[0] = "battlecity level 3"; return l }, want: []pos{{1, 18}}},
+		{name: "not key value", edit: func(l []string) []string { l[2] = "author Tester"; return l }, want: []pos{{3, 0}}},
+		{name: "unknown key", edit: func(l []string) []string { l[2] = "colour: red"; return l }, want: []pos{{3, 1}}},
+		{name: "key twice", edit: func(l []string) []string { l[2] = "name: Again"; return l }, want: []pos{{3, 1}}},
+		{name: "missing key", edit: func(l []string) []string { return append(l[:7:7], l[8:]...) }, want: []pos{{9, 0}}},
+		{name: "unknown tank", edit: func(l []string) []string { l[3] = "enemies: 18 basic, 2 fest"; return l }, want: []pos{{4, 20}}},
+		{name: "bad count", edit: func(l []string) []string { l[3] = "enemies: many basic"; return l }, want: []pos{{4, 10}, {4, 10}}},
+		{name: "too many enemies", edit: func(l []string) []string { l[3] = "enemies: 9999999999 basic"; return l }, want: []pos{{4, 10}}},
+		{name: "too many in all", edit: func(l []string) []string { l[3] = "enemies: 400 basic, 400 fast"; return l }, want: []pos{{4, 21}}},
+		{name: "bad order", edit: func(l []string) []string { l[4] = "order:  sorted"; return l }, want: []pos{{5, 9}}},
+		{name: "bad point", edit: func(l []string) []string { l[5] = "spawns: 3,3 192;3"; return l }, want: []pos{{6, 13}}},
+		{name: "spawn off field", edit: func(l []string) []string { l[5] = "spawns: 3,3 400,3"; return l }, want: []pos{{6, 13}}},
+		{name: "nan player", edit: func(l []string) []string { l[6] = "players: NaN,NaN 243,384"; return l }, want: []pos{{7, 10}, {7, 10}}},
+		{name: "infinite player", edit: func(l []string) []string { l[6] = "players: 144,384 +Inf,384"; return l }, want: []pos{{7, 18}, {7, 10}}},
+		{name: "one player", edit: func(l []string) []string { l[6] = "players: 144,384"; return l }, want: []pos{{7, 10}}},
+		{name: "castle off grid", edit: func(l []string) []string { l[7] = "castle: 190,384"; return l }, want: []pos{{8, 9}}},
+		{name: "bad max enemies", edit: func(l []string) []string { l[8] = "max-enemies: 0"; return l }, want: []pos{{9, 14}}},
+	}
+	for _, tt := range tests {
+		data := tt.data
+		if !tt.raw {
+			lines := testLevel()
+			if tt.edit != nil {
+				lines = tt.edit(lines)
+			}
+			data = strings.Join(lines, "\n") + "\n"
+		}
+		l, err := DecodeLevel([]byte(data))
+		if len(tt.want) == 0 {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			} else if len(l.Grid) != GridSize {
+				t.Errorf("%s: grid has %d rows", tt.name, len(l.Grid))
+			}
+			continue
+		}
+		var errs LevelErrors
+		if !errors.As(err, &errs) {
+			t.Errorf("%s: got %v, want LevelErrors", tt.name, err)
+			continue
+		}
+		var got []pos
+		for _, e := range errs {
+			got = append(got, pos{e.Line, e.Col})
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got errors at %v, want %v:\n%v", tt.name, got, tt.want, err)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: got errors at %v, want %v:\n%v", tt.name, got, tt.want, err)
+				break
+			}
+		}
+	}
+}
+
+func TestDecodeLevelHeader(t *testing.T) {
+	l, err := DecodeLevel([]byte(strings.Join(testLevel(), "\n") + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Name != "Test" || l.Author != "Tester" || l.Shuffle || l.MaxEnemies != 4 {
+		t.Errorf("got name %q, author %q, shuffle %v, max %d", l.Name, l.Author, l.Shuffle, l.MaxEnemies)
+	}
+	if len(l.Enemies) != 20 || l.Enemies[17] != BasicTank || l.Enemies[18] != FastTank {
+		t.Errorf("got roster %v", l.Enemies)
+	}
+	if len(l.Spawns) != 3 || l.Spawns[2] != [2]float64{381, 3} || l.Players[1] != [2]float64{243, 384} || l.Castle != [2]float64{192, 384} {
+		t.Errorf("got spawns %v, players %v, castle %v", l.Spawns, l.Players, l.Castle)
+	}
+
+	// A plain grid gets what NewLevel gives.
+	plain, err := DecodeLevel([]byte(strings.Join(testLevel()[10:], "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	def := NewLevel()
+	if len(plain.Enemies) != len(def.Enemies) || !plain.Shuffle || len(plain.Spawns) != len(def.Spawns) || plain.Players != def.Players {
+		t.Errorf("plain grid got roster %v, spawns %v, players %v", plain.Enemies, plain.Spawns, plain.Players)
+	}
+}
+
+func TestLevelEncodeRoundTrip(t *testing.T) {
+	levels, err := LoadLevels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, l := range levels {
+		again, err := DecodeLevel(l.Encode())
+		if err != nil {
+			t.Fatalf("level %d: %v", i+1, err)
+		}
+		if string(again.Encode()) != string(l.Encode()) {
+			t.Errorf("level %d changed going through Encode and DecodeLevel", i+1)
+		}
+	}
+}
+
+func TestLoadLevels(t *testing.T) {
+	file := func(name string) *fstest.MapFile {
+		lines := testLevel()
+		lines[1] = "name: " + name
+		return &fstest.MapFile{Data: []byte(strings.Join(lines, "\n"))}
+	}
+	fsys := fstest.MapFS{
+		"3":       file("Three"),
+		"bonus":   file("Bonus"),
+		"40":      file("Forty"),
+		"alpha":   file("Alpha"),
+		"9a":      file("Nine A"),
+		".hidden": {Data: []byte("not a level")},
+		"sub/4":   file("Nested"),
+	}
+	ls, err := LoadLevels(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	embedded, err := LevelFiles(EmbeddedLevels())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []string
+	for i := range embedded {
+		want = append(want, fmt.Sprintf("Stage %d", i+1))
+	}
+	want[2] = "Three"
+	want = append(want, "Forty", "Nine A", "Alpha", "Bonus")
+	var got []string
+	for _, l := range ls {
+		got = append(got, l.Name)
+	}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("LoadLevels names\n\t%s\nwant\n\t%s", strings.Join(got, ", "), strings.Join(want, ", "))
+	}
+}
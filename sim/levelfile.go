@@ -0,0 +1,49 @@
+package sim
+
+import (
+	"archive/zip"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// OpenLevels opens a directory or .zip archive of level files. An archive
+// holding nothing but one directory is read from inside that directory. The
+// closer has to be closed once the levels are read.
+func OpenLevels(path string) (fs.FS, io.Closer, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.IsDir() {
+		return os.DirFS(path), io.NopCloser(nil), nil
+	}
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var fsys fs.FS = zr
+	entries, err := fs.ReadDir(zr, ".")
+	if err == nil && len(entries) == 1 && entries[0].IsDir() {
+		fsys, err = fs.Sub(zr, entries[0].Name())
+	}
+	if err != nil {
+		zr.Close()
+		return nil, nil, err
+	}
+	return fsys, zr, nil
+}
+
+// LoadLevelsFrom reads the embedded levels and, given a path, the level files
+// in the directory or archive there on top of them.
+func LoadLevelsFrom(path string) ([]*Level, error) {
+	if path == "" {
+		return LoadLevels()
+	}
+	fsys, closer, err := OpenLevels(path)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+	return LoadLevels(fsys)
+}
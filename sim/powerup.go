@@ -0,0 +1,120 @@
+package sim
+
+type PowerUpType int
+
+const (
+	Grenade PowerUpType = iota
+	Helmet
+	Shovel
+	Star
+	ExtraTank
+	Timer
+	PowerUpTypes int = iota
+)
+
+const (
+	powerUpWidth  = 2 * TileSize
+	powerUpHeight = 2 * TileSize
+	maxStars      = 3
+	helmetTicks   = 10 * TickRate
+	shovelTicks   = 20 * TickRate
+	timerTicks    = 10 * TickRate
+)
+
+// bonusEnemies are the positions in a level's enemy queue that come out as
+// flashing bonus tanks and drop a power-up when hit.
+var bonusEnemies = map[int]bool{3: true, 10: true, 17: true}
+
+// castleWall returns the tiles around the castle that the shovel turns to
+// steel, leaving out any past the edge of the field.
+func (w *World) castleWall() [][2]int {
+	c0, r0 := int(w.Castle.X)/TileSize, int(w.Castle.Y)/TileSize
+	c1, r1 := c0+w.Castle.Width/TileSize, r0+w.Castle.Height/TileSize
+	var wall [][2]int
+	for r := r0 - 1; r <= r1; r++ {
+		for c := c0 - 1; c <= c1; c++ {
+			inside := c >= c0 && c < c1 && r >= r0 && r < r1
+			if !inside && c >= 0 && r >= 0 && c < ScreenWidth/TileSize && r < ScreenHeight/TileSize {
+				wall = append(wall, [2]int{c, r})
+			}
+		}
+	}
+	return wall
+}
+
+type PowerUp struct {
+	Width  int
+	Height int
+	X      float64
+	Y      float64
+	T      PowerUpType
+}
+
+func (p *PowerUp) GetInfo() (Width, Height int, X, Y float64) {
+	return p.Width, p.Height, p.X, p.Y
+}
+
+// dropPowerUp puts a random power-up on a random spot of the tile grid,
+// replacing the one on the field if any.
+func (w *World) dropPowerUp() {
+	w.PowerUp = &PowerUp{
+		Width:  powerUpWidth,
+		Height: powerUpHeight,
+		X:      float64(w.rng.Intn(ScreenWidth/TileSize-1) * TileSize),
+		Y:      float64(w.rng.Intn(ScreenHeight/TileSize-1) * TileSize),
+		T:      PowerUpType(w.rng.Intn(PowerUpTypes)),
+	}
+}
+
+// pickUp gives the power-up on the field to player t when it drives over it.
+func (w *World) pickUp(t *Tank) {
+	p := w.PowerUp
+	if p == nil || t.Failed || !CheckCollision(p, t, false) {
+		return
+	}
+	w.PowerUp = nil
+	switch p.T {
+	case Grenade:
+		// Enemies blown up by a grenade are worth nothing.
+		for _, e := range append([]*Tank(nil), w.Enemys...) {
+			w.removeEnemy(e)
+		}
+	case Helmet:
+		t.Shield = helmetTicks
+	case Shovel:
+		w.Effects[Shovel] = shovelTicks
+		w.fortify(Steel)
+	case Star:
+		if t.Stars < maxStars {
+			t.Stars++
+		}
+	case ExtraTank:
+		t.Lives++
+	case Timer:
+		w.Effects[Timer] = timerTicks
+	}
+}
+
+// updateEffects counts down the timed power-ups and undoes them when they run out.
+func (w *World) updateEffects() {
+	for i := range w.Effects {
+		if w.Effects[i] == 0 {
+			continue
+		}
+		w.Effects[i]--
+		if w.Effects[i] == 0 && PowerUpType(i) == Shovel {
+			w.fortify(Brick)
+		}
+	}
+}
+
+// fortify rebuilds the wall around the castle out of tiles of type t.
+func (w *World) fortify(t int) {
+	for _, pos := range w.castleWall() {
+		x, y := float64(pos[0]*TileSize), float64(pos[1]*TileSize)
+		if o := w.Grid.TileAt(pos[0], pos[1]); o != nil {
+			w.removeOther(o)
+		}
+		w.addOther(NewOther(x, y, t))
+	}
+}
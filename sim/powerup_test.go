@@ -0,0 +1,191 @@
+package sim
+
+import (
+	"strings"
+	"testing"
+)
+
+// openWorld is a one player world on a level with nothing but open ground,
+// or the given grid rows over the top of it.
+func openWorld(rows ...string) *World {
+	l := NewLevel()
+	for r := 0; r < GridSize; r++ {
+		row := strings.Repeat(".", GridSize)
+		if r < len(rows) {
+			row = rows[r]
+		}
+		l.Grid = append(l.Grid, row)
+	}
+	return NewWorld([]*Level{l}, 1, false, 1)
+}
+
+func TestBonusEnemies(t *testing.T) {
+	w := openWorld()
+	for i := range w.Enemies_left {
+		w.Generate_enemy()
+		if len(w.Enemys) != 1 {
+			t.Fatalf("enemy %d did not come out", i)
+		}
+		e := w.Enemys[0]
+		if want := i == 3 || i == 10 || i == 17; e.Bonus != want {
+			t.Errorf("enemy %d: bonus %v, want %v", i, e.Bonus, want)
+		}
+		if e.Bonus {
+			w.shoot(w.P0.Fire(), e)
+			if e.Bonus || w.PowerUp == nil {
+				t.Errorf("enemy %d: hitting a bonus tank left bonus %v, power-up %v", i, e.Bonus, w.PowerUp)
+			}
+		}
+		w.removeEnemy(e)
+	}
+}
+
+func TestBonusEnemyClearsPowerUp(t *testing.T) {
+	w := openWorld()
+	w.Idx = 3
+	w.dropPowerUp()
+	w.Generate_enemy()
+	if w.PowerUp != nil {
+		t.Errorf("power-up %v still on the field when a bonus tank came out", w.PowerUp)
+	}
+}
+
+func TestPickUp(t *testing.T) {
+	tests := []struct {
+		typ   PowerUpType
+		check func(w *World) string
+	}{
+		{Grenade, func(w *World) string {
+			if len(w.Enemys) != 0 || w.P0.Score != 0 {
+				return "grenade left enemies or scored"
+			}
+			return ""
+		}},
+		{Helmet, func(w *World) string {
+			if w.P0.Shield != helmetTicks {
+				return "no helmet shield"
+			}
+			return ""
+		}},
+		{Shovel, func(w *World) string {
+			if w.Effects[Shovel] != shovelTicks || !walls(w, Steel) {
+				return "castle not walled in steel"
+			}
+			return ""
+		}},
+		{Star, func(w *World) string {
+			if w.P0.Stars != 1 {
+				return "no star"
+			}
+			return ""
+		}},
+		{ExtraTank, func(w *World) string {
+			if w.P0.Lives != NewPlayer(0).Lives+1 {
+				return "no extra life"
+			}
+			return ""
+		}},
+		{Timer, func(w *World) string {
+			if w.Effects[Timer] != timerTicks {
+				return "enemies not stopped"
+			}
+			return ""
+		}},
+	}
+	for _, tt := range tests {
+		w := openWorld()
+		w.Generate_enemy()
+		w.Generate_enemy()
+		p := w.P0
+		w.PowerUp = &PowerUp{Width: powerUpWidth, Height: powerUpHeight, X: p.X, Y: p.Y, T: tt.typ}
+		w.pickUp(p)
+		if w.PowerUp != nil {
+			t.Errorf("power-up %d was not picked up", tt.typ)
+			continue
+		}
+		if msg := tt.check(w); msg != "" {
+			t.Errorf("power-up %d: %s", tt.typ, msg)
+		}
+	}
+}
+
+func TestPickUpMissed(t *testing.T) {
+	w := openWorld()
+	w.PowerUp = &PowerUp{Width: powerUpWidth, Height: powerUpHeight, X: 0, Y: 0, T: Star}
+	w.pickUp(w.P0)
+	if w.PowerUp == nil || w.P0.Stars != 0 {
+		t.Errorf("picked up a power-up across the field")
+	}
+}
+
+func TestStarsCap(t *testing.T) {
+	w := openWorld()
+	for i := 0; i < maxStars+2; i++ {
+		w.PowerUp = &PowerUp{Width: powerUpWidth, Height: powerUpHeight, X: w.P0.X, Y: w.P0.Y, T: Star}
+		w.pickUp(w.P0)
+	}
+	if w.P0.Stars != maxStars {
+		t.Errorf("got %d stars, want %d", w.P0.Stars, maxStars)
+	}
+}
+
+// walls reports whether every tile around the castle is a whole tile of type typ.
+func walls(w *World, typ int) bool {
+	for _, pos := range w.castleWall() {
+		o := w.Grid.TileAt(pos[0], pos[1])
+		if o == nil || o.T != typ || !o.Whole() {
+			return false
+		}
+	}
+	return true
+}
+
+func TestEffectsRunOut(t *testing.T) {
+	w := openWorld()
+	w.Effects[Timer] = 3
+	w.Effects[Shovel] = 2
+	w.fortify(Steel)
+	for i := 0; i < 2; i++ {
+		if !walls(w, Steel) {
+			t.Fatalf("steel walls gone with %d ticks of shovel left", w.Effects[Shovel])
+		}
+		w.updateEffects()
+	}
+	if w.Effects[Shovel] != 0 || !walls(w, Brick) {
+		t.Errorf("castle not back to brick when the shovel ran out")
+	}
+	if w.Effects[Timer] != 1 {
+		t.Errorf("timer has %d ticks left, want 1", w.Effects[Timer])
+	}
+	w.updateEffects()
+	w.updateEffects()
+	if w.Effects[Timer] != 0 {
+		t.Errorf("timer went on to %d", w.Effects[Timer])
+	}
+}
+
+func TestFortifyRestoresWalls(t *testing.T) {
+	// The arcade wall around the castle, with a gap shot in it and a
+	// chipped brick.
+	rows := make([]string, GridSize)
+	for r := range rows {
+		rows[r] = strings.Repeat(".", GridSize)
+	}
+	rows[23] = "..........." + "####" + "..........."
+	rows[24] = "..........." + "#..#" + "..........."
+	rows[25] = rows[24]
+	w := openWorld(rows...)
+	w.removeOther(w.Grid.TileAt(12, 23))
+	w.Grid.TileAt(11, 24).Mask = 1
+	if walls(w, Brick) {
+		t.Fatal("wall already whole")
+	}
+	n := len(w.Others)
+	w.fortify(Brick)
+	if !walls(w, Brick) {
+		t.Error("fortify left the wall broken")
+	}
+	if len(w.Others) != n+1 {
+		t.Errorf("%d tiles after fortify, want %d", len(w.Others), n+1)
+	}
+}
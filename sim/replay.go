@@ -0,0 +1,122 @@
+package sim
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+const replayVersion = 1
+
+// Replay is everything needed to play a match back: the world setup and the
+// inputs of both players for every tick, two packed bytes per tick.
+type Replay struct {
+	Version   int    `json:"version"`
+	Seed      int64  `json:"seed"`
+	Level     int    `json:"level"`
+	TwoPlayer bool   `json:"two_player"`
+	Rules     *Rules `json:"rules,omitempty"` // nil means ArcadeRules
+	Inputs    []byte `json:"inputs"`
+}
+
+func NewReplay(seed int64, level int, twoPlayer bool) *Replay {
+	return &Replay{
+		Version:   replayVersion,
+		Seed:      seed,
+		Level:     level,
+		TwoPlayer: twoPlayer,
+	}
+}
+
+func LoadReplay(path string) (*Replay, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &Replay{}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("replay %s: %w", path, err)
+	}
+	if r.Version != replayVersion {
+		return nil, fmt.Errorf("replay %s: unsupported version %d", path, r.Version)
+	}
+	if len(r.Inputs)%2 != 0 {
+		return nil, fmt.Errorf("replay %s: truncated inputs", path)
+	}
+	return r, nil
+}
+
+func (r *Replay) Save(path string) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Record appends the inputs of one tick.
+func (r *Replay) Record(inputs [2]Input) {
+	r.Inputs = append(r.Inputs, inputs[0].pack(), inputs[1].pack())
+}
+
+// Len returns the number of recorded ticks.
+func (r *Replay) Len() int {
+	return len(r.Inputs) / 2
+}
+
+// Tick returns the inputs recorded for tick i.
+func (r *Replay) Tick(i int) [2]Input {
+	return [2]Input{unpackInput(r.Inputs[2*i]), unpackInput(r.Inputs[2*i+1])}
+}
+
+// NewWorld builds the world the replay was recorded against, which has to
+// have been on levels.
+func (r *Replay) NewWorld(levels []*Level) (*World, error) {
+	if r.Level < 1 || r.Level > len(levels) {
+		return nil, fmt.Errorf("replay starts on level %d of %d", r.Level, len(levels))
+	}
+	w := NewWorld(levels, r.Level, r.TwoPlayer, r.Seed)
+	if r.Rules != nil {
+		w.SetRules(*r.Rules)
+	}
+	return w, nil
+}
+
+// Sources returns one input source per recorded player.
+func (r *Replay) Sources() [2]InputSource {
+	return [2]InputSource{&ReplayInput{Replay: r, Player: 0}, &ReplayInput{Replay: r, Player: 1}}
+}
+
+// Play runs the whole replay on levels without rendering and returns the
+// final world.
+func (r *Replay) Play(levels []*Level) (*World, error) {
+	w, err := r.NewWorld(levels)
+	if err != nil {
+		return nil, err
+	}
+	sources := r.Sources()
+	for i := 0; i < r.Len() && w.State != GameOver; i++ {
+		w.Step(Poll(sources))
+	}
+	return w, nil
+}
+
+func (in Input) pack() byte {
+	var b byte
+	for i, held := range [5]bool{in.Up, in.Right, in.Down, in.Left, in.Fire} {
+		if held {
+			b |= 1 << i
+		}
+	}
+	return b
+}
+
+func unpackInput(b byte) Input {
+	return Input{
+		Up:    b&(1<<0) != 0,
+		Right: b&(1<<1) != 0,
+		Down:  b&(1<<2) != 0,
+		Left:  b&(1<<3) != 0,
+		Fire:  b&(1<<4) != 0,
+	}
+}
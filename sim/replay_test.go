@@ -0,0 +1,48 @@
+package sim
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestReplayRoundTrip(t *testing.T) {
+	levels, err := LoadLevels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := ArcadeRules
+	rules.PlayerFire = FriendlyFireOn
+	const seed = 7
+	w := NewWorld(levels, 2, true, seed)
+	w.SetRules(rules)
+	r := NewReplay(seed, 2, true)
+	r.Rules = &rules
+	sources := [2]InputSource{drive(), InputFunc(func() Input { return Input{Left: true, Fire: true} })}
+	for i := 0; i < 15*TickRate && w.State != GameOver; i++ {
+		inputs := Poll(sources)
+		r.Record(inputs)
+		w.Step(inputs)
+	}
+
+	path := filepath.Join(t.TempDir(), "match.replay")
+	if err := r.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadReplay(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Len() != r.Len() {
+		t.Fatalf("loaded %d ticks, recorded %d", loaded.Len(), r.Len())
+	}
+	played, err := loaded.Play(levels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := played.Outcome(), w.Outcome(); got != want {
+		t.Errorf("played back %q, recorded %q", got, want)
+	}
+	if got, want := snapshot(played), snapshot(w); got != want {
+		t.Errorf("played back world differs:\n%s\nvs recorded\n%s", got, want)
+	}
+}
@@ -0,0 +1,92 @@
+package sim
+
+import "fmt"
+
+type Team int
+
+const (
+	TeamPlayers Team = iota
+	TeamEnemies
+	// TeamRival is player 2's team in versus games.
+	TeamRival
+)
+
+// FriendlyFire is what a bullet does to a tank on its own team.
+type FriendlyFire int
+
+const (
+	// FriendlyFireOff lets the bullet fly through.
+	FriendlyFireOff FriendlyFire = iota
+	// FriendlyFireFreeze stops the bullet and freezes the tank for a while.
+	FriendlyFireFreeze
+	// FriendlyFireOn hurts the tank as if an opponent had hit it.
+	FriendlyFireOn
+)
+
+var friendlyFireNames = [...]string{"off", "freeze", "on"}
+
+func (f FriendlyFire) String() string {
+	if f < 0 || int(f) >= len(friendlyFireNames) {
+		return fmt.Sprintf("FriendlyFire(%d)", int(f))
+	}
+	return friendlyFireNames[f]
+}
+
+func ParseFriendlyFire(s string) (FriendlyFire, error) {
+	for i, name := range friendlyFireNames {
+		if s == name {
+			return FriendlyFire(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown friendly fire policy %q, want off, freeze or on", s)
+}
+
+// Rules sets who can hurt whom and how tightly tanks turn onto the grid.
+type Rules struct {
+	PlayerFire FriendlyFire `json:"player_fire"` // player bullets hitting a teammate
+	EnemyFire  FriendlyFire `json:"enemy_fire"`  // enemy bullets hitting another enemy
+	// Versus puts the players on opposing teams. They still share the
+	// field, and the castle, with the enemies.
+	Versus bool `json:"versus"`
+	// PlayerSnap and EnemySnap are how far, in pixels, a tank turning onto
+	// the other axis may be pulled onto the tile grid. Half a tile or more
+	// always snaps, like the original; 0 turns tanks in place.
+	PlayerSnap float64 `json:"player_snap"`
+	EnemySnap  float64 `json:"enemy_snap"`
+}
+
+// ArcadeRules are those of the original game: enemies can't hurt each other,
+// a player shot by the other one is frozen for a few seconds, and tanks
+// line up with the grid whenever they turn.
+var ArcadeRules = Rules{
+	PlayerFire: FriendlyFireFreeze,
+	EnemyFire:  FriendlyFireOff,
+	PlayerSnap: snapGrid / 2,
+	EnemySnap:  snapGrid / 2,
+}
+
+// freezeTicks is how long a tank hit by friendly fire can't move or shoot.
+const freezeTicks = 4 * TickRate
+
+// SetRules changes the rules of the match and the teams of the players with them.
+func (w *World) SetRules(r Rules) {
+	w.Rules = r
+	if w.P1 == nil {
+		return
+	}
+	w.P1.Team = TeamPlayers
+	if r.Versus {
+		w.P1.Team = TeamRival
+	}
+}
+
+// fireRule returns what b does to t.
+func (w *World) fireRule(b *Bullet, t *Tank) FriendlyFire {
+	if b.Owner.Team != t.Team {
+		return FriendlyFireOn
+	}
+	if b.Owner.Enemy {
+		return w.Rules.EnemyFire
+	}
+	return w.Rules.PlayerFire
+}
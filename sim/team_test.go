@@ -0,0 +1,25 @@
+package sim
+
+import "testing"
+
+func TestFriendlyFreeze(t *testing.T) {
+	tests := []struct {
+		name   string
+		shield int
+		frozen int
+	}{
+		{"unshielded", 0, freezeTicks},
+		{"shielded", 3 * TickRate, 0},
+	}
+	for _, tt := range tests {
+		w := NewWorld([]*Level{NewLevel()}, 1, true, 1)
+		w.P1.Shield = tt.shield
+		w.shoot(&Bullet{Owner: w.P0}, w.P1)
+		if w.P1.Frozen != tt.frozen {
+			t.Errorf("%s: Frozen = %d, want %d", tt.name, w.P1.Frozen, tt.frozen)
+		}
+		if w.P1.Lives != NewPlayer(1).Lives || w.P1.Failed {
+			t.Errorf("%s: friendly fire cost a life", tt.name)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package sim
+
+import "math"
+
+// blast breaks the terrain b hit somewhere along path, the box it swept over
+// this tick. As in the original, a shot takes half a tile off the side it
+// hits, along a strip two tiles wide centered on the bullet, so a few shots
+// dig a lane a tank fits through.
+func (w *World) blast(b *Bullet, path Rect) {
+	cx, cy := b.X+float64(b.Width)/2, b.Y+float64(b.Height)/2
+	const depth, half = TileSize / 2, TileSize
+
+	// The strip starts at the face of the nearest standing cell the bullet
+	// ran into.
+	edge := math.Inf(1)
+	if b.F == 0 || b.F == 3 {
+		edge = math.Inf(-1)
+	}
+	for _, o := range w.Grid.Tiles(path.X, path.Y, path.W, path.H) {
+		if !o.StopsBullet() || !o.Overlaps(path.X, path.Y, path.W, path.H) {
+			continue
+		}
+		for r := 0; r < CellsPerSide; r++ {
+			for c := 0; c < CellsPerSide; c++ {
+				x, y := o.cellRect(r, c)
+				if !o.Cell(r, c) || !path.Overlaps(Rect{x, y, CellSize, CellSize}) {
+					continue
+				}
+				switch b.F {
+				case 0:
+					edge = math.Max(edge, y+CellSize)
+				case 1:
+					edge = math.Min(edge, x)
+				case 2:
+					edge = math.Min(edge, y)
+				default:
+					edge = math.Max(edge, x+CellSize)
+				}
+			}
+		}
+	}
+
+	var x, y, width, height float64
+	switch b.F {
+	case 0:
+		x, y, width, height = cx-half, edge-depth, 2*half, depth
+	case 1:
+		x, y, width, height = edge, cy-half, depth, 2*half
+	case 2:
+		x, y, width, height = cx-half, edge, 2*half, depth
+	default:
+		x, y, width, height = edge-depth, cy-half, depth, 2*half
+	}
+	for _, o := range w.Grid.Tiles(x, y, width, height) {
+		if !o.StopsBullet() || !o.Overlaps(x, y, width, height) || !o.Breaks(b.Power) {
+			continue
+		}
+		if o.T != Brick || !o.clear(x, y, width, height) {
+			w.removeOther(o)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package sim
+
+import (
+	"strings"
+	"testing"
+)
+
+// grid is an open field with the tiles given as column, row and character.
+func grid(tiles map[[2]int]byte) []string {
+	rows := make([][]byte, GridSize)
+	for r := range rows {
+		rows[r] = []byte(strings.Repeat(".", GridSize))
+	}
+	for pos, ch := range tiles {
+		rows[pos[1]][pos[0]] = ch
+	}
+	s := make([]string, GridSize)
+	for r, row := range rows {
+		s[r] = string(row)
+	}
+	return s
+}
+
+// cells is the mask of the cells in the given rows and columns.
+func cells(rows, cols []int) uint16 {
+	var m uint16
+	for _, r := range rows {
+		for _, c := range cols {
+			m |= 1 << (r*CellsPerSide + c)
+		}
+	}
+	return m
+}
+
+func TestBlast(t *testing.T) {
+	all := []int{0, 1, 2, 3}
+	tests := []struct {
+		name  string
+		tiles map[[2]int]byte
+		mask  uint16 // when set, the tile at 5,5 starts chipped to this
+		b     Bullet
+		path  Rect
+		want  map[[2]int]uint16 // 0 means the tile is gone
+	}{
+		{
+			name:  "up into the middle of a tile",
+			tiles: map[[2]int]byte{{4, 5}: '#', {5, 5}: '#', {6, 5}: '#'},
+			b:     Bullet{Width: 6, Height: 8, X: 85, Y: 94, F: 0},
+			path:  Rect{85, 94, 6, 14},
+			want: map[[2]int]uint16{
+				{4, 5}: fullMask &^ cells([]int{2, 3}, []int{2, 3}),
+				{5, 5}: cells([]int{0, 1}, all),
+				{6, 5}: fullMask &^ cells([]int{2, 3}, []int{0, 1}),
+			},
+		},
+		{
+			name:  "down into the top of a tile",
+			tiles: map[[2]int]byte{{5, 5}: '#'},
+			b:     Bullet{Width: 6, Height: 8, X: 85, Y: 76, F: 2},
+			path:  Rect{85, 66, 6, 18},
+			want:  map[[2]int]uint16{{5, 5}: cells([]int{2, 3}, all)},
+		},
+		{
+			name:  "right across two tiles",
+			tiles: map[[2]int]byte{{5, 5}: '#', {5, 6}: '#'},
+			b:     Bullet{Width: 8, Height: 6, X: 76, Y: 93, F: 1},
+			path:  Rect{68, 93, 16, 6},
+			want: map[[2]int]uint16{
+				{5, 5}: cells(all, []int{2, 3}),
+				{5, 6}: cells(all, []int{2, 3}),
+			},
+		},
+		{
+			name:  "left into a tile at the field edge",
+			tiles: map[[2]int]byte{{0, 5}: '#'},
+			b:     Bullet{Width: 8, Height: 6, X: 12, Y: 85, F: 3},
+			path:  Rect{12, 85, 16, 6},
+			want:  map[[2]int]uint16{{0, 5}: cells(all, []int{0, 1})},
+		},
+		{
+			name:  "up by the field edge",
+			tiles: map[[2]int]byte{{0, 5}: '#', {1, 5}: '#'},
+			b:     Bullet{Width: 6, Height: 8, X: 1, Y: 94, F: 0},
+			path:  Rect{1, 94, 6, 14},
+			want: map[[2]int]uint16{
+				{0, 5}: cells([]int{0, 1}, all),
+				{1, 5}: fullMask &^ cells([]int{2, 3}, []int{0}),
+			},
+		},
+		{
+			name:  "up into a chipped tile",
+			tiles: map[[2]int]byte{{5, 5}: '#'},
+			mask:  cells([]int{0, 1}, all),
+			b:     Bullet{Width: 6, Height: 8, X: 85, Y: 86, F: 0},
+			path:  Rect{85, 86, 6, 14},
+			want:  map[[2]int]uint16{{5, 5}: 0},
+		},
+		{
+			name:  "steel without stars",
+			tiles: map[[2]int]byte{{5, 5}: '@'},
+			b:     Bullet{Width: 6, Height: 8, X: 85, Y: 94, F: 0},
+			path:  Rect{85, 94, 6, 14},
+			want:  map[[2]int]uint16{{5, 5}: fullMask},
+		},
+		{
+			name:  "steel with three stars",
+			tiles: map[[2]int]byte{{5, 5}: '@'},
+			b:     Bullet{Width: 6, Height: 8, X: 85, Y: 94, F: 0, Power: maxStars},
+			path:  Rect{85, 94, 6, 14},
+			want:  map[[2]int]uint16{{5, 5}: 0},
+		},
+	}
+	for _, tt := range tests {
+		w := openWorld(grid(tt.tiles)...)
+		if tt.mask != 0 {
+			w.Grid.TileAt(5, 5).Mask = tt.mask
+		}
+		b := tt.b
+		w.blast(&b, tt.path)
+		for pos, want := range tt.want {
+			o := w.Grid.TileAt(pos[0], pos[1])
+			switch {
+			case want == 0 && o != nil:
+				t.Errorf("%s: tile %v left with mask %#04x, want it gone", tt.name, pos, o.Mask)
+			case want != 0 && o == nil:
+				t.Errorf("%s: tile %v gone, want mask %#04x", tt.name, pos, want)
+			case o != nil && o.Mask != want:
+				t.Errorf("%s: tile %v mask %#04x, want %#04x", tt.name, pos, o.Mask, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package sim
+
+import "math"
+
+// snapGrid is the grid tanks line up on when they turn. The original snaps
+// to half of a map block, which is one of our tiles.
+const snapGrid = TileSize
+
+// snapTolerance is how far, in pixels, t may be pulled onto the grid when it
+// turns.
+func (w *World) snapTolerance(t *Tank) float64 {
+	if t.Enemy {
+		return w.Rules.EnemySnap
+	}
+	return w.Rules.PlayerSnap
+}
+
+// snap returns where t ends up when it turns to face dir. As in the original,
+// turning onto the other axis centers the tank on the nearest grid line
+// of the axis it stops moving along, which is what lets it drive into a gap
+// two tiles wide. Lines further away than the snap tolerance are left alone.
+func (w *World) snap(t *Tank, dir int) (x, y float64) {
+	x, y = t.X, t.Y
+	tolerance := w.snapTolerance(t)
+	if dir%2 == t.Face%2 || tolerance <= 0 {
+		return x, y
+	}
+	if dir%2 == 0 {
+		x = align(x, float64(t.Width), tolerance)
+	} else {
+		y = align(y, float64(t.Height), tolerance)
+	}
+	return x, y
+}
+
+// align moves the segment at p of length size so its middle is on the
+// grid, unless that is more than tolerance away.
+func align(p, size, tolerance float64) float64 {
+	mid := p + size/2
+	d := math.Round(mid/snapGrid)*snapGrid - mid
+	if math.Abs(d) > tolerance {
+		return p
+	}
+	return p + d
+}
+
+// turn faces t toward dir, snapping it onto the grid unless that would run it
+// into something.
+func (w *World) turn(t *Tank, dir int) {
+	if x, y := w.snap(t, dir); (x != t.X || y != t.Y) && !w.blocked(t, x, y) {
+		t.X, t.Y = x, y
+		w.Grid.MoveTank(t)
+	}
+	t.Face = dir
+}
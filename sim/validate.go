@@ -0,0 +1,120 @@
+package sim
+
+import (
+	"fmt"
+	"math"
+)
+
+// Problems lists what makes l unplayable even though it decodes: anything
+// built where the castle goes, spawn points or player starts that are walled
+// in, and spawn points from which enemies can't get to the castle. Bricks
+// don't cut a path, enemies shoot their way through those.
+func (l *Level) Problems() []string {
+	var problems []string
+	castle := Rect{l.Castle[0], l.Castle[1], castleWidth, castleHeight}
+	c0, r0 := int(castle.X)/TileSize, int(castle.Y)/TileSize
+	for r := r0; r < r0+castleHeight/TileSize; r++ {
+		for c := c0; c < c0+castleWidth/TileSize; c++ {
+			if ch := l.tile(c, r); ch != '.' {
+				problems = append(problems, fmt.Sprintf("castle area has %q at row %d, column %d", ch, r+1, c+1))
+			}
+		}
+	}
+
+	walls := func(ch byte) bool { return ch == '#' || ch == '@' || ch == '%' }
+	for i, p := range l.Spawns {
+		if l.blocked(block(p, enemyWidth, enemyHeight), walls) {
+			problems = append(problems, fmt.Sprintf("spawn %d at %s is blocked", i+1, formatPoints(p)))
+		}
+	}
+	for i, p := range l.Players {
+		if l.blocked(block(p, playerWidth, playerHeight), walls) {
+			problems = append(problems, fmt.Sprintf("player %d start at %s is blocked", i+1, formatPoints(p)))
+		}
+	}
+
+	for i, p := range l.Spawns {
+		if !l.reaches(p, castle) {
+			problems = append(problems, fmt.Sprintf("no way for a tank from spawn %d at %s to the castle", i+1, formatPoints(p)))
+		}
+	}
+	return problems
+}
+
+// block is the two by two tiles a w by h tank at p stands on: those around
+// the grid line its middle snaps to when it turns.
+func block(p [2]float64, w, h float64) Rect {
+	x := align(p[0], w, snapGrid) + w/2 - TileSize
+	y := align(p[1], h, snapGrid) + h/2 - TileSize
+	return Rect{x, y, 2 * TileSize, 2 * TileSize}
+}
+
+// tile returns the grid character at column c and row r, or 0 off the grid.
+func (l *Level) tile(c, r int) byte {
+	if r < 0 || r >= len(l.Grid) || c < 0 || c >= len(l.Grid[r]) {
+		return 0
+	}
+	return l.Grid[r][c]
+}
+
+// blocked reports whether box sticks out of the field or overlaps the castle
+// or a tile wall says a tank can't be on.
+func (l *Level) blocked(box Rect, wall func(byte) bool) bool {
+	if box.X < 0 || box.Y < 0 || box.X+box.W > ScreenWidth || box.Y+box.H > ScreenHeight {
+		return true
+	}
+	if box.Overlaps(Rect{l.Castle[0], l.Castle[1], castleWidth, castleHeight}) {
+		return true
+	}
+	c0, c1 := int(box.X)/TileSize, int(math.Ceil((box.X+box.W)/TileSize))
+	r0, r1 := int(box.Y)/TileSize, int(math.Ceil((box.Y+box.H)/TileSize))
+	for r := r0; r < r1; r++ {
+		for c := c0; c < c1; c++ {
+			if wall(l.tile(c, r)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// reaches reports whether an enemy at from can drive, a pixel at a time,
+// up against the castle without crossing steel or water.
+func (l *Level) reaches(from [2]float64, castle Rect) bool {
+	walls := func(ch byte) bool { return ch == '@' || ch == '%' }
+	// Positions are kept at from's offset from the pixel grid.
+	fx, fy := from[0]-math.Floor(from[0]), from[1]-math.Floor(from[1])
+	const n = ScreenWidth + 1
+	seen := make([]bool, n*n)
+	box := func(i int) Rect {
+		return Rect{fx + float64(i%n), fy + float64(i/n), enemyWidth, enemyHeight}
+	}
+	// The start itself isn't checked: a spawn point may overlap a wall by a
+	// pixel the enemy backs off from, and one that is walled in is already
+	// reported as blocked.
+	start := int(from[1]-fy)*n + int(from[0]-fx)
+	if start < 0 || start >= len(seen) {
+		return false
+	}
+	seen[start] = true
+	queue := []int{start}
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		if box(i).Touches(castle) {
+			return true
+		}
+		x, y := i%n, i/n
+		for _, next := range [4][2]int{{x, y - 1}, {x + 1, y}, {x, y + 1}, {x - 1, y}} {
+			if next[0] < 0 || next[1] < 0 || next[0] >= n || next[1] >= n {
+				continue
+			}
+			j := next[1]*n + next[0]
+			if !seen[j] && !l.blocked(box(j), walls) {
+				seen[j] = true
+				queue = append(queue, j)
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package sim
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// row fills columns c0 to c1 of a grid row with ch.
+func row(r, c0, c1 int, ch byte) map[[2]int]byte {
+	tiles := make(map[[2]int]byte)
+	for c := c0; c <= c1; c++ {
+		tiles[[2]int{c, r}] = ch
+	}
+	return tiles
+}
+
+// with merges tile maps, later ones on top.
+func with(maps ...map[[2]int]byte) map[[2]int]byte {
+	tiles := make(map[[2]int]byte)
+	for _, m := range maps {
+		for pos, ch := range m {
+			tiles[pos] = ch
+		}
+	}
+	return tiles
+}
+
+func TestProblems(t *testing.T) {
+	// A ring of ch around the top left spawn's tiles.
+	ring := func(ch byte) map[[2]int]byte {
+		return with(row(2, 0, 2, ch), map[[2]int]byte{{2, 0}: ch, {2, 1}: ch})
+	}
+	tests := []struct {
+		name  string
+		tiles map[[2]int]byte
+		want  []string
+	}{
+		{"open", nil, nil},
+		{"brick all the way across", row(5, 0, GridSize-1, '#'), nil},
+		{"steel a pixel under a spawn", row(2, 0, 1, '@'), nil},
+		{
+			"spawn in brick",
+			map[[2]int]byte{{0, 0}: '#'},
+			[]string{"spawn 1 at 3,3 is blocked"},
+		},
+		{
+			"spawn walled in with brick",
+			ring('#'),
+			nil,
+		},
+		{
+			"spawn walled in with steel",
+			ring('@'),
+			[]string{"no way for a tank from spawn 1 at 3,3 to the castle"},
+		},
+		{
+			"spawn walled in with water",
+			ring('%'),
+			[]string{"no way for a tank from spawn 1 at 3,3 to the castle"},
+		},
+		{
+			"spawns sealed off by steel and water",
+			with(row(5, 0, 12, '@'), row(5, 13, GridSize-1, '%')),
+			[]string{
+				"no way for a tank from spawn 1 at 3,3 to the castle",
+				"no way for a tank from spawn 2 at 192,3 to the castle",
+				"no way for a tank from spawn 3 at 381,3 to the castle",
+			},
+		},
+		{
+			"a gap a tank doesn't fit through",
+			with(row(5, 0, 11, '@'), row(5, 13, GridSize-1, '@')),
+			[]string{
+				"no way for a tank from spawn 1 at 3,3 to the castle",
+				"no way for a tank from spawn 2 at 192,3 to the castle",
+				"no way for a tank from spawn 3 at 381,3 to the castle",
+			},
+		},
+		{
+			"a gap a tank fits through",
+			with(row(5, 0, 11, '@'), row(5, 14, GridSize-1, '@')),
+			nil,
+		},
+		{
+			"player start in steel",
+			map[[2]int]byte{{9, 25}: '@'},
+			[]string{"player 1 start at 144,384 is blocked"},
+		},
+		{
+			"grass where the castle goes",
+			map[[2]int]byte{{13, 24}: '~'},
+			[]string{"castle area has '~' at row 25, column 14"},
+		},
+	}
+	for _, tt := range tests {
+		l := NewLevel()
+		l.Grid = grid(tt.tiles)
+		if got := l.Problems(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Problems() =\n\t%s\nwant\n\t%s", tt.name, strings.Join(got, "\n\t"), strings.Join(tt.want, "\n\t"))
+		}
+	}
+}
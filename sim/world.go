@@ -0,0 +1,605 @@
+// Package sim holds the Battle City rules and world state. It has no
+// rendering or input dependencies, so a match can be stepped without a window.
+package sim
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// State is where the match stands.
+type State int
+
+const (
+	Playing State = iota
+	StageClear
+	GameOver
+)
+
+// TickRate is how many ticks make a second. Speeds are in pixels per second
+// and timers count ticks, so a match plays out the same however fast the
+// caller gets around to stepping the world.
+const TickRate = 60
+
+// iceSlideTicks is how long a player keeps sliding after letting go on ice.
+const iceSlideTicks = TickRate / 4
+
+// stageClearTicks is how long the stage clear tally stays up before the
+// next level starts.
+const stageClearTicks = 5 * TickRate
+
+// World keeps its entities in slices rather than maps so that every tick
+// visits them in the same order, which keeps a seeded run reproducible.
+type World struct {
+	P0        *Tank
+	P1        *Tank
+	TwoPlayer bool
+	Bullets   []*Bullet
+	// players   map[*Tank]struct{}
+	Enemys       []*Tank
+	Castle       *Castle
+	Levels       []*Level
+	Level        int    // number of the level being played, counting from 1
+	Map          *Level // the level being played
+	MapLevel     []string
+	Others       []*Other
+	Grid         *Grid // where the tiles and tanks are, for collision queries
+	Enemies_left []int
+	Idx          int
+	Seed         int64
+	Tick         int
+	State        State
+	PowerUp      *PowerUp
+	Effects      [PowerUpTypes]int // ticks left of the field wide power-ups
+	Rules        Rules
+	wait         int
+	rng          *rand.Rand
+}
+
+// NewWorld starts level of levels, counting from 1, with every gameplay
+// random draw taken from seed.
+func NewWorld(levels []*Level, level int, twoPlayer bool, seed int64) *World {
+	w := &World{
+		Levels:    levels,
+		TwoPlayer: twoPlayer,
+		Level:     level,
+		Seed:      seed,
+		Rules:     ArcadeRules,
+		rng:       rand.New(rand.NewSource(seed)),
+	}
+	w.addPlayer()
+	w.init()
+	return w
+}
+
+func (w *World) init() {
+	// w.players = make(map[*Tank]struct{})
+	w.Enemys = nil
+	w.Bullets = nil
+	w.Others = nil
+	w.Grid = NewGrid(ScreenWidth, ScreenHeight)
+	m := w.Levels[w.Level-1]
+	w.Map = m
+	w.Castle = NewCastle(m.Castle[0], m.Castle[1])
+	for i, t := range [2]*Tank{w.P0, w.P1} {
+		if t == nil {
+			continue
+		}
+		if !t.Out() {
+			t.X, t.Y = m.start(i)
+			t.settle()
+		}
+		w.Grid.MoveTank(t)
+	}
+	w.Enemies_left = []int{}
+	for _, t := range m.Enemies {
+		w.Enemies_left = append(w.Enemies_left, int(t))
+	}
+	if m.Shuffle {
+		w.rng.Shuffle(len(w.Enemies_left), func(i, j int) {
+			w.Enemies_left[i], w.Enemies_left[j] = w.Enemies_left[j], w.Enemies_left[i]
+		})
+	}
+	w.Idx = 0
+	w.MapLevel = m.Grid
+	w.ParseLevel()
+}
+
+func (w *World) addPlayer() {
+	w.P0 = NewPlayer(0)
+	if w.TwoPlayer {
+		w.P1 = NewPlayer(1)
+	}
+}
+
+// NextLevel loads the level after the current one, going back to the first
+// after the last. Players still in the game keep going from their start spots.
+func (w *World) NextLevel() {
+	w.Level = w.Level%len(w.Levels) + 1
+	w.State = Playing
+	w.PowerUp = nil
+	w.Effects = [PowerUpTypes]int{}
+	for _, t := range [2]*Tank{w.P0, w.P1} {
+		if t == nil {
+			continue
+		}
+		t.Kills = [EnemyTypes]int{}
+		if !t.Out() {
+			t.Face = 0
+			t.Failed = false
+			t.Shield = shieldTicks
+		}
+	}
+	w.init()
+}
+
+// respawn brings a destroyed player with lives left back at its start spot,
+// unless another tank is in the way.
+func (w *World) respawn(t *Tank, player int) {
+	if !t.Failed || t.Lives == 0 {
+		return
+	}
+	x0, y0 := t.X, t.Y
+	t.X, t.Y = w.Map.start(player)
+	if w.PosConflict(t) {
+		t.X, t.Y = x0, y0
+		return
+	}
+	t.settle()
+	w.Grid.MoveTank(t)
+	t.Failed = false
+	t.Face = 0
+	t.Shield = shieldTicks
+}
+
+// checkState ends the match once the castle is gone or every player is out,
+// and starts the stage clear countdown once the level's enemies are used up.
+func (w *World) checkState() {
+	if w.Castle.Mode == 2 || (w.P0.Out() && (w.P1 == nil || w.P1.Out())) {
+		w.State = GameOver
+		return
+	}
+	if w.Idx == len(w.Enemies_left) && len(w.Enemys) == 0 {
+		w.State = StageClear
+		w.wait = stageClearTicks
+	}
+}
+
+// Step advances the world by one tick. inputs[1] is ignored in one player games.
+// Once the game is over Step does nothing.
+func (w *World) Step(inputs [2]Input) {
+	if w.State == GameOver {
+		return
+	}
+	w.Tick++
+	for _, t := range [2]*Tank{w.P0, w.P1} {
+		if t != nil {
+			t.settle()
+		}
+	}
+	for _, e := range w.Enemys {
+		e.settle()
+	}
+	if w.State == StageClear {
+		w.wait--
+		if w.wait == 0 {
+			w.NextLevel()
+		}
+		return
+	}
+	for _, bullet := range append([]*Bullet(nil), w.Bullets...) {
+		if bullet.gone {
+			// Already taken out by a bullet that moved before it.
+			continue
+		}
+		if bullet.X <= 0 || bullet.X >= ScreenWidth || bullet.Y <= 0 || bullet.Y >= ScreenHeight {
+			w.removeBullet(bullet)
+		} else {
+			x0, y0 := bullet.X, bullet.Y
+			bullet.Move()
+			if !w.intercept(bullet, x0, y0) {
+				w.HitAndRemove(bullet, x0, y0)
+			}
+		}
+	}
+
+	w.updateEffects()
+	for _, e := range w.Enemys {
+		if e.Frozen > 0 {
+			e.Frozen--
+			continue
+		}
+		// The timer power-up freezes every enemy in place.
+		if w.Effects[Timer] > 0 {
+			continue
+		}
+		dir := w.GetDirection(e)
+		if dir == -1 {
+			e.Face = (e.Face + 2) % 2
+		} else if dir == e.Face {
+			w.Move(e)
+		} else {
+			w.turn(e, dir)
+		}
+		w.enemyFire(e)
+	}
+
+	w.Generate_enemy()
+	w.Castle.Update()
+
+	for i, t := range [2]*Tank{w.P0, w.P1} {
+		if t == nil {
+			continue
+		}
+		w.respawn(t, i)
+		if t.Shield > 0 {
+			t.Shield--
+		}
+		if t.Frozen > 0 {
+			t.Frozen--
+		}
+		w.Control(t, inputs[i])
+		w.pickUp(t)
+	}
+	w.checkState()
+}
+
+// Outcome sums up the match so far in one line.
+func (w *World) Outcome() string {
+	killed := w.Idx - len(w.Enemys)
+	left := len(w.Enemies_left) - killed
+	s := fmt.Sprintf("level %d after %d ticks: %d enemies destroyed, %d left, player 1 %s",
+		w.Level, w.Tick, killed, left, playerState(w.P0))
+	if w.P1 != nil {
+		s += ", player 2 " + playerState(w.P1)
+	}
+	if w.State == GameOver {
+		s += ", game over"
+	}
+	return s
+}
+
+func playerState(t *Tank) string {
+	if t.Out() {
+		return fmt.Sprintf("%d points, out", t.Score)
+	}
+	return fmt.Sprintf("%d points, %d lives left", t.Score, t.Lives)
+}
+
+// Control applies one tick of player input to t: a held direction turns the
+// tank, or moves it when it already faces that way, otherwise it may fire.
+func (w *World) Control(t *Tank, in Input) {
+	if t.Failed || t.Frozen > 0 {
+		return
+	}
+	if dir := in.Dir(); dir != -1 {
+		if t.Face == dir {
+			w.Move(t)
+			if w.onIce(t) {
+				t.Slide = iceSlideTicks
+			}
+		} else {
+			w.turn(t, dir)
+		}
+		return
+	}
+	// Let go on ice and the tank keeps sliding for a bit.
+	if t.Slide > 0 {
+		t.Slide--
+		if w.Move(t) || !w.onIce(t) {
+			t.Slide = 0
+		}
+	}
+	if in.Fire && w.bulletsOf(t) < t.MaxBullets() {
+		b := t.Fire()
+		w.addBullet(b)
+	}
+}
+
+// onIce reports whether t is standing on any ice.
+func (w *World) onIce(t *Tank) bool {
+	for _, o := range w.Grid.Tiles(t.X, t.Y, float64(t.Width), float64(t.Height)) {
+		if o.T == Ice && CheckCollision(t, o, false) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *World) Move(t *Tank) bool {
+	// var x, y float64
+	if t.Failed {
+		return false
+	}
+	x0, y0 := t.X, t.Y
+	switch t.Face {
+	case 0:
+		t.Y -= t.step()
+	case 1:
+		t.X += t.step()
+	case 2:
+		t.Y += t.step()
+	default:
+		t.X -= t.step()
+	}
+
+	if w.blocked(t, t.X, t.Y) {
+		t.X, t.Y = x0, y0
+		return true
+	}
+	w.Grid.MoveTank(t)
+	return false
+
+}
+
+// blocked reports whether t placed at x, y would run into another tank, a
+// tile tanks can't cross or the castle, or stick out of the field.
+func (w *World) blocked(t *Tank, x, y float64) bool {
+	width, height := float64(t.Width), float64(t.Height)
+	if x < 0 || y < 0 || x+width > ScreenWidth || y+height > ScreenHeight {
+		return true
+	}
+	if RectCollision(w.Castle.Width, w.Castle.Height, w.Castle.X, w.Castle.Y, t.Width, t.Height, x, y, true) {
+		return true
+	}
+	for _, other := range w.Grid.Tanks(x, y, width, height) {
+		if other == t || other.Failed {
+			continue
+		}
+		W, H, X, Y := other.GetInfo()
+		if RectCollision(W, H, X, Y, t.Width, t.Height, x, y, true) {
+			return true
+		}
+	}
+	for _, o := range w.Grid.Tiles(x, y, width, height) {
+		if o.BlocksTank() && o.Overlaps(x, y, width, height) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *World) EnemyMove(t *Tank) {
+	// var x, y float64
+	if t.Failed {
+		return
+	}
+	switch t.Face {
+	case 0:
+		t.Y -= t.step()
+	case 1:
+		t.X += t.step()
+	case 2:
+		t.Y += t.step()
+	default:
+		t.X -= t.step()
+	}
+}
+
+// HitAndRemove resolves what b ran into on its way from x0, y0 to where it
+// is now. Only the first thing along the path counts, so a fast bullet can't
+// pass through a thin wall or a tank between two ticks.
+func (w *World) HitAndRemove(b *Bullet, x0, y0 float64) {
+	from := Rect{x0, y0, float64(b.Width), float64(b.Height)}
+	dx, dy := b.X-x0, b.Y-y0
+	path := from.Union(Bounds(b))
+
+	first := math.Inf(1)
+	var tank *Tank
+	var tile *Other
+	castle := false
+	for _, t := range w.Grid.Tanks(path.X, path.Y, path.W, path.H) {
+		if t.Failed || b.Owner == t || w.fireRule(b, t) == FriendlyFireOff {
+			continue
+		}
+		if at, hit := from.Sweep(dx, dy, Bounds(t)); hit && at < first {
+			first, tank = at, t
+		}
+	}
+	if at, hit := from.Sweep(dx, dy, Bounds(w.Castle)); hit && at < first {
+		first, tank, castle = at, nil, true
+	}
+	for _, o := range w.Grid.Tiles(path.X, path.Y, path.W, path.H) {
+		if !o.StopsBullet() {
+			continue
+		}
+		if at, hit := o.Sweep(from, dx, dy); hit && at < first {
+			first, tank, tile, castle = at, nil, o, false
+		}
+	}
+
+	switch {
+	case castle:
+		w.Castle.Hit()
+		w.removeBullet(b)
+	case tile != nil:
+		w.blast(b, path)
+		w.removeBullet(b)
+	case tank != nil:
+		w.removeBullet(b)
+		w.shoot(b, tank)
+	}
+}
+
+// shoot applies a hit by b to t.
+func (w *World) shoot(b *Bullet, t *Tank) {
+	if w.fireRule(b, t) == FriendlyFireFreeze {
+		if t.Shield > 0 {
+			return
+		}
+		t.Frozen = freezeTicks
+		return
+	}
+	if !t.Enemy {
+		t.Hit()
+		return
+	}
+	// A bonus tank drops its power-up on the first hit, even one
+	// that armor shrugs off.
+	if t.Bonus {
+		t.Bonus = false
+		w.dropPowerUp()
+	}
+	t.HP--
+	if t.HP > 0 {
+		return
+	}
+	w.removeEnemy(t)
+	if !b.Owner.Enemy {
+		b.Owner.Kills[t.Type]++
+		b.Owner.Score += t.Type.Points()
+	}
+}
+
+// intercept cancels b and the first bullet of the other side it runs into,
+// and reports whether that happened.
+func (w *World) intercept(b *Bullet, x0, y0 float64) bool {
+	from := Rect{x0, y0, float64(b.Width), float64(b.Height)}
+	for _, other := range w.Bullets {
+		if other == b || other.Owner.Team == b.Owner.Team {
+			continue
+		}
+		if _, hit := from.Sweep(b.X-x0, b.Y-y0, Bounds(other)); hit {
+			w.removeBullet(other)
+			w.removeBullet(b)
+			return true
+		}
+	}
+	return false
+}
+
+func (w *World) bulletsOf(t *Tank) int {
+	n := 0
+	for _, b := range w.Bullets {
+		if b.Owner == t {
+			n++
+		}
+	}
+	return n
+}
+
+func (w *World) addBullet(bullet *Bullet) {
+	w.Bullets = append(w.Bullets, bullet)
+}
+
+func (w *World) addOther(o *Other) {
+	w.Others = append(w.Others, o)
+	w.Grid.AddTile(o)
+}
+
+func (w *World) addEnermy(enemy *Tank) {
+	w.Enemys = append(w.Enemys, enemy)
+	w.Grid.MoveTank(enemy)
+}
+
+func (w *World) removeBullet(bullet *Bullet) {
+	bullet.gone = true
+	for i, b := range w.Bullets {
+		if b == bullet {
+			w.Bullets = append(w.Bullets[:i], w.Bullets[i+1:]...)
+			return
+		}
+	}
+}
+
+func (w *World) removeOther(o *Other) {
+	for i, other := range w.Others {
+		if other == o {
+			w.Others = append(w.Others[:i], w.Others[i+1:]...)
+			w.Grid.RemoveTile(o)
+			return
+		}
+	}
+}
+
+func (w *World) removeEnemy(enemy *Tank) {
+	for i, e := range w.Enemys {
+		if e == enemy {
+			w.Enemys = append(w.Enemys[:i], w.Enemys[i+1:]...)
+			w.Grid.RemoveTank(enemy)
+			return
+		}
+	}
+}
+
+// PosConflict reports whether t would be too close to any other tank on the field.
+func (w *World) PosConflict(t *Tank) bool {
+	// NotSafe keeps centers a tank diagonal apart, so nothing further away counts.
+	r := math.Hypot(enemyWidth, enemyHeight)
+	for _, other := range w.Grid.Tanks(t.X-r, t.Y-r, 2*r, 2*r) {
+		if other != t && !other.Failed && NotSafe(other, t) {
+			return true
+		}
+	}
+	return false
+
+}
+
+func (w *World) Generate_enemy() {
+	if w.Idx < len(w.Enemies_left) {
+		// f := w.rng.Intn(4)
+		pos := w.Map.Spawns[w.rng.Intn(len(w.Map.Spawns))]
+		e := NewEnemy(EnemyType(w.Enemies_left[w.Idx]), w.rng.Intn(4), pos[0], pos[1])
+		if w.PosConflict(e) || len(w.Enemys) >= w.Map.MaxEnemies {
+			return
+		}
+		if bonusEnemies[w.Idx] {
+			// A new bonus tank clears the power-up still on the field.
+			e.Bonus = true
+			w.PowerUp = nil
+		}
+		w.addEnermy(e)
+		w.Idx++
+	}
+}
+
+func (w *World) OutOfScreen(e Entity) bool {
+	width, height, x, y := e.GetInfo()
+	x1, y1 := float64(width)+x, float64(height)+y
+	if x < 0 || y < 0 {
+		return true
+	}
+	if x1 > ScreenWidth || y1 > ScreenHeight {
+		return true
+	}
+	return false
+
+}
+
+func (w *World) GetDirection(t *Tank) int {
+	var directions [4]int
+	cur_dir := t.Face
+	dir0 := (t.Face + 1) % 4
+	oppo_dir := (t.Face + 2) % 4
+	dir1 := (t.Face + 3) % 4
+
+	r := w.rng.Intn(2)
+	if r == 0 {
+		directions = [4]int{cur_dir, dir0, dir1, oppo_dir}
+	} else {
+		directions = [4]int{cur_dir, dir1, dir0, oppo_dir}
+	}
+
+	for _, dir := range directions {
+		// Try the move from where turning would leave the tank.
+		x, y := w.snap(t, dir)
+		if w.blocked(t, x, y) {
+			x, y = t.X, t.Y
+		}
+		switch dir {
+		case 0:
+			y -= t.step()
+		case 1:
+			x += t.step()
+		case 2:
+			y += t.step()
+		case 3:
+			x -= t.step()
+		}
+		if !w.blocked(t, x, y) {
+			return dir
+		}
+	}
+	return -1
+}
@@ -0,0 +1,77 @@
+package sim
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// snapshot describes everything in w that play depends on.
+func snapshot(w *World) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s state %d idx %d\n", w.Outcome(), w.State, w.Idx)
+	for _, t := range append([]*Tank{w.P0, w.P1}, w.Enemys...) {
+		if t != nil {
+			fmt.Fprintf(&b, "%+v\n", *t)
+		}
+	}
+	for _, bl := range w.Bullets {
+		fmt.Fprintf(&b, "bullet %v,%v %d %d\n", bl.X, bl.Y, bl.F, bl.Power)
+	}
+	for _, o := range w.Others {
+		fmt.Fprintf(&b, "tile %v,%v %d\n", o.X, o.Y, o.T)
+	}
+	if p := w.PowerUp; p != nil {
+		fmt.Fprintf(&b, "power-up %v,%v %d\n", p.X, p.Y, p.T)
+	}
+	return b.String()
+}
+
+// drive turns and fires every so often, so the players move around the
+// field and shoot at things.
+func drive() *Script {
+	s := &Script{Loop: true}
+	for d := 0; d < 4; d++ {
+		for i := 0; i < 90; i++ {
+			in := Input{Fire: i%20 == 0}
+			switch d {
+			case 0:
+				in.Up = true
+			case 1:
+				in.Right = true
+			case 2:
+				in.Down = true
+			case 3:
+				in.Left = true
+			}
+			s.Inputs = append(s.Inputs, in)
+		}
+	}
+	return s
+}
+
+func TestWorldDeterministic(t *testing.T) {
+	levels, err := LoadLevels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	const seed, ticks = 42, 20 * TickRate
+	var worlds [2]*World
+	var sources [2][2]InputSource
+	for i := range worlds {
+		worlds[i] = NewWorld(levels, 1, true, seed)
+		sources[i] = [2]InputSource{drive(), InputFunc(func() Input { return Input{Fire: true} })}
+	}
+	for tick := 1; tick <= ticks; tick++ {
+		for i, w := range worlds {
+			w.Step(Poll(sources[i]))
+		}
+		a, b := snapshot(worlds[0]), snapshot(worlds[1])
+		if a != b {
+			t.Fatalf("worlds with seed %d differ after tick %d:\n%s\nvs\n%s", seed, tick, a, b)
+		}
+	}
+	if worlds[0].Idx == 0 {
+		t.Errorf("no enemy came out in %d ticks", ticks)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/ShaolingPu/battleCity/sim"
+)
+
+// validateLevels runs the validate-levels subcommand. It checks every level
+// file in the directory or .zip archive named in args, or the embedded levels
+// without one, prints what is wrong with them and returns the exit code.
+func validateLevels(args []string) int {
+	if len(args) > 1 {
+		fmt.Fprintln(os.Stderr, "usage: battlecity validate-levels [dir|zip]")
+		return 2
+	}
+	fsys, where := sim.EmbeddedLevels(), "embedded levels"
+	if len(args) == 1 {
+		var closer io.Closer
+		var err error
+		fsys, closer, err = sim.OpenLevels(args[0])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		defer closer.Close()
+		where = args[0]
+	}
+	names, err := sim.LevelFiles(fsys)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if len(names) == 0 {
+		fmt.Printf("no level files in %s\n", where)
+		return 1
+	}
+
+	bad := 0
+	for _, name := range names {
+		problems := checkLevel(fsys, name)
+		if len(problems) == 0 {
+			continue
+		}
+		bad++
+		if len(args) == 1 {
+			name = filepath.Join(where, name)
+		}
+		fmt.Printf("%s:\n", name)
+		for _, p := range problems {
+			fmt.Printf("\t%s\n", p)
+		}
+	}
+	fmt.Printf("%d levels checked in %s, %d with problems\n", len(names), where, bad)
+	if bad > 0 {
+		return 1
+	}
+	return 0
+}
+
+// checkLevel lists the problems with level file name, from not decoding to
+// not being playable.
+func checkLevel(fsys fs.FS, name string) []string {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	l, err := sim.DecodeLevel(data)
+	var errs sim.LevelErrors
+	if errors.As(err, &errs) {
+		problems := make([]string, len(errs))
+		for i, e := range errs {
+			problems[i] = e.Error()
+		}
+		return problems
+	} else if err != nil {
+		return []string{err.Error()}
+	}
+	return l.Problems()
+}